@@ -4,12 +4,14 @@ import (
 	"FuzzerMan/pkg/config"
 	"FuzzerMan/pkg/tasks"
 	"context"
-	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
@@ -43,8 +45,11 @@ func main() {
 		&tasks.FuzzTask{},
 		&tasks.CorpusMergeTask{},
 		&tasks.SyncTargetBinaryTask{},
+		&tasks.SyncDictionaryTask{},
+		&tasks.CrashReproduceTask{},
 	}
-	c := context.Background()
+	c, cancel := context.WithCancel(context.Background())
+	handleSignals(cancel, cfg.GracePeriod())
 
 	for _, t := range taskList {
 		if err := t.Initialize(c, cfg); err != nil {
@@ -52,13 +57,37 @@ func main() {
 		}
 	}
 
-	for {
+	for c.Err() == nil {
 		for _, t := range taskList {
-			if err := t.Run(); err != nil {
+			if c.Err() != nil {
+				break
+			}
+			if err := t.Run(); err != nil && c.Err() == nil {
 				log.Printf("[!] ERROR: %s", err.Error())
 			}
 		}
 	}
+	log.Printf("[*] Shutdown complete")
+}
+
+// handleSignals cancels the task context on SIGINT/SIGTERM so the running task can wrap up, if the tasks have not
+// finished by the end of the grace period the process exits anyway.
+func handleSignals(cancel context.CancelFunc, gracePeriod time.Duration) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Printf("[*] Received %s, shutting down (grace period: %s)", sig, gracePeriod)
+		cancel()
+
+		select {
+		case sig = <-signals:
+			log.Printf("[!] Received %s again, exiting immediately", sig)
+		case <-time.After(gracePeriod):
+			log.Printf("[!] Grace period expired, exiting")
+		}
+		os.Exit(1)
+	}()
 }
 
 func testConfig(c *config.Config) error {
@@ -86,18 +115,13 @@ func testConfig(c *config.Config) error {
 		return err
 	}
 	if err := syncBinary.Run(); err != nil {
-		return err
-	}
-
-	// Ensure the target binary is executable
-	if info, err := os.Stat(c.FilePath(config.LocalFuzzerFile)); err != nil {
-		return errors.New(fmt.Sprintf("unable to stat target binary: %s", err.Error()))
-	} else {
-		// Check for executable bit to be set on any of owner/group/everyone
-		if info.Mode()&0111 == 0 {
-			return errors.New("target binary is not executable")
+		// A refused or failed update only matters when there is no previously synced binary to keep running
+		if tasks.CheckTargetBinaries(c) != nil {
+			return err
 		}
+		log.Printf("[!] Failed to sync target binary, keeping the current one: %s", err.Error())
 	}
 
-	return nil
+	// Ensure the target binaries are executable, a campaign with targets may not have a `fuzzer` of its own
+	return tasks.CheckTargetBinaries(c)
 }
@@ -29,14 +29,19 @@ func GenerateTaskConfig(host config.HostConfig, campaign config.CampaignConfig,
 		WorkDirectory:     path.Join(host.WorkDirectory, campaign.Id),
 		ReportingEndpoint: campaign.ReportingEndpoint,
 		InitScript:        "",
+		TrustedKeys:       host.TrustedKeys,
 		CloudStorage:      campaign.CloudStorage,
 		Fuzzer: config.FuzzerConfig{
-			ForkCount:         coreCount,
-			MaxTotalTime:      campaign.MaxTotalTime,
-			IncludeHostEnv:    campaign.IncludeHostEnv,
-			Arguments:         campaign.Arguments,
-			Environment:       campaign.Environment,
-			UploadOnlyCrashes: campaign.UploadOnlyCrashes,
+			Engine:              campaign.Engine,
+			FuzzFunction:        campaign.FuzzFunction,
+			EntryPoint:          campaign.EntryPoint,
+			ForkCount:           coreCount,
+			MaxTotalTime:        campaign.MaxTotalTime,
+			IncludeHostEnv:      campaign.IncludeHostEnv,
+			Arguments:           campaign.Arguments,
+			Environment:         campaign.Environment,
+			UploadOnlyCrashes:   campaign.UploadOnlyCrashes,
+			UpdateCheckInterval: campaign.UpdateCheckInterval,
 		},
 	}
 	if host.EnableMergeTask && campaign.MergeInterval > 0 {
@@ -45,6 +50,30 @@ func GenerateTaskConfig(host config.HostConfig, campaign config.CampaignConfig,
 	} else {
 		cfg.MergeTask.Enabled = false
 	}
+	cfg.CorpusManifest.Enabled = campaign.CorpusManifest
+	cfg.CorpusManifest.MaxAgeHours = campaign.ManifestMaxAgeHours
+	cfg.CorpusShards.Enabled = campaign.CorpusShards
+	cfg.CorpusShards.Format = campaign.ShardFormat
+	cfg.CorpusShards.MaxShardSizeMB = campaign.MaxShardSizeMB
+	cfg.CrashDedup.Enabled = campaign.DedupCrashes
+	cfg.CrashDedup.StackDepth = campaign.DedupStackDepth
+	cfg.Reproduce.Enabled = campaign.ReproduceCrashes
+	cfg.Reproduce.Runs = campaign.ReproduceRuns
+	cfg.Reproduce.SkipNonReproducible = campaign.SkipNonReproducible
+	cfg.Minimize.Enabled = campaign.MinimizeCrashes
+	cfg.Minimize.Runs = campaign.MinimizeRuns
+	cfg.Minimize.MaxTotalTime = campaign.MinimizeMaxTotalTime
+	cfg.Retention.MaxAgeHours = host.RetentionMaxAgeHours
+	cfg.Retention.MaxSizeMB = host.RetentionMaxSizeMB
+	cfg.Sandbox.Enabled = campaign.Sandbox
+	cfg.Sandbox.WritablePaths = campaign.SandboxWritablePaths
+	if campaign.CgroupMemoryMaxMB > 0 || campaign.CgroupCPUs > 0 || campaign.CgroupPidsMax > 0 {
+		cfg.Cgroup.Enabled = true
+		cfg.Cgroup.Parent = host.CgroupParent
+		cfg.Cgroup.MemoryMaxMB = campaign.CgroupMemoryMaxMB
+		cfg.Cgroup.CPUs = campaign.CgroupCPUs
+		cfg.Cgroup.PidsMax = campaign.CgroupPidsMax
+	}
 	return &cfg
 }
 
@@ -63,8 +92,21 @@ func runCampaignUntil(end time.Time, cfg *config.Config) error {
 		return err
 	}
 	if err := syncBinary.Run(); err != nil {
+		// A refused or failed update only matters when there is no previously synced binary to keep running
+		if tasks.CheckTargetBinaries(cfg) != nil {
+			return err
+		}
+		log.Printf("Failed to sync target binary, keeping the current one: %s", err.Error())
+	}
+
+	syncDictionary := tasks.SyncDictionaryTask{}
+	if err := syncDictionary.Initialize(context.Background(), cfg); err != nil {
 		return err
 	}
+	if err := syncDictionary.Run(); err != nil {
+		// Dictionaries are optional, the campaign can fuzz without them
+		log.Printf("Failed to sync dictionaries: %s", err.Error())
+	}
 
 	if cfg.MergeTask.Enabled {
 		mergeTask := tasks.CorpusMergeTask{}
@@ -80,6 +122,10 @@ func runCampaignUntil(end time.Time, cfg *config.Config) error {
 	if err := fuzzTask.Initialize(context.Background(), cfg); err != nil {
 		return err
 	}
+	reproduceTask := tasks.CrashReproduceTask{}
+	if err := reproduceTask.Initialize(context.Background(), cfg); err != nil {
+		return err
+	}
 
 	// We'll run the fuzzer until time is up, but if we are within 5-minutes of the end time don't bother
 	for time.Now().Before(end.Add(-5 * time.Minute)) {
@@ -94,6 +140,9 @@ func runCampaignUntil(end time.Time, cfg *config.Config) error {
 			log.Printf("Failed to run fuzz task: %s", err.Error())
 			time.Sleep(15 * time.Second)
 		}
+		if err := reproduceTask.Run(); err != nil {
+			log.Printf("Failed to run reproduce task: %s", err.Error())
+		}
 	}
 	return nil
 }
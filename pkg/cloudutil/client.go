@@ -4,24 +4,27 @@ import (
 	"cloud.google.com/go/storage"
 	"context"
 	"golang.org/x/sync/semaphore"
-	"sync"
+	"time"
 )
 
 type Client struct {
 	CredentialFile string
-	context        context.Context
-	client         *storage.Client
-	bucket         string
-	sema           *semaphore.Weighted
-	wg             *sync.WaitGroup
+	// RetryAttempts and RetryDelay control how transfers are retried, the delay doubles after each attempt
+	RetryAttempts int
+	RetryDelay    time.Duration
+	context       context.Context
+	client        *storage.Client
+	bucket        string
+	sema          *semaphore.Weighted
 }
 
 func NewClient(ctx context.Context, bucketUrl string) *Client {
 	out := Client{
-		context: ctx,
-		bucket:  bucketUrl,
-		sema:    semaphore.NewWeighted(16),
-		wg:      &sync.WaitGroup{},
+		RetryAttempts: DefaultRetryAttempts,
+		RetryDelay:    DefaultRetryDelay,
+		context:       ctx,
+		bucket:        bucketUrl,
+		sema:          semaphore.NewWeighted(16),
 	}
 	return &out
 }
@@ -0,0 +1,300 @@
+package cloudutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A manifest lets a prefix be mirrored without listing it. The manifest is made up of three parts under a base key:
+//
+//   - `<base>.json` a snapshot of every file under the prefix, written by the prefix's authority (the merge task)
+//   - `<base>.generation` the generation of the latest snapshot, it is the only object read on every sync
+//   - `<base>.d/<time>-<uuid>.json` deltas listing the files added since a snapshot, written by everyone else
+//
+// Deltas are named by the time they were written. Writers' clocks can be behind and a delta can still be uploading
+// while others list them, so the names can't be used as a cursor. Instead each instance lists every delta from
+// shortly before the snapshot and skips the ones it already applied.
+
+// Manifest is the snapshot of a prefix's files
+type Manifest struct {
+	Generation int64     `json:"generation"`
+	Created    time.Time `json:"created"`
+	Files      []string  `json:"files"`
+}
+
+// ManifestDelta lists the files added to a prefix after a snapshot
+type ManifestDelta struct {
+	Added []string `json:"added"`
+}
+
+// ManifestState is an instance's progress through a manifest, it is kept locally between syncs
+type ManifestState struct {
+	Generation int64     `json:"generation"`
+	Created    time.Time `json:"created"`
+	// Applied holds the names of the deltas applied on top of the snapshot
+	Applied map[string]bool `json:"applied"`
+}
+
+// deltaWindow is how long before a snapshot deltas are still applied on top of it, covering writers whose clock is
+// behind and deltas that were being written while the corpus was listed
+const deltaWindow = 15 * time.Minute
+
+// deltaCursor is the smallest delta name written at or after t
+func deltaCursor(t time.Time) string {
+	return fmt.Sprintf("%020d", t.UnixNano())
+}
+
+// ManifestGeneration returns the generation of the latest snapshot, 0 if there is none
+func (c *Client) ManifestGeneration(base string) (int64, error) {
+	content, err := c.ReadFile(base+".generation", nil)
+	if err != nil {
+		if gcerrors.Code(err) == gcerrors.NotFound {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+}
+
+// WriteManifest writes a new snapshot with the next generation. created should be no later than the moment the
+// files were listed, deltas written since then are applied on top of the snapshot. Deltas older than the previous
+// snapshot are no longer needed and are removed.
+func (c *Client) WriteManifest(base string, files []string, created time.Time) error {
+	generation, err := c.ManifestGeneration(base)
+	if err != nil {
+		return err
+	}
+	var previous Manifest
+	if content, err := c.ReadFile(base+".json", nil); err == nil {
+		_ = json.Unmarshal(content, &previous)
+	}
+
+	manifest := Manifest{Generation: generation + 1, Created: created, Files: files}
+	content, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if err = c.WriteFile(base+".json", content, &blob.WriterOptions{CacheControl: "no-cache"}); err != nil {
+		return err
+	}
+	generationContent := []byte(strconv.FormatInt(manifest.Generation, 10))
+	if err = c.WriteFile(base+".generation", generationContent, &blob.WriterOptions{CacheControl: "no-cache"}); err != nil {
+		return err
+	}
+
+	// Instances still on the previous snapshot may need its deltas until their next sync, and deltas from the window
+	// before the previous snapshot may still be applied on top of it
+	if !previous.Created.IsZero() {
+		deltas, err := c.listDeltas(base, "")
+		if err != nil {
+			return err
+		}
+		b, err := blob.OpenBucket(c.context, c.bucket)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = b.Close() }()
+		cutoff := deltaCursor(previous.Created.Add(-deltaWindow))
+		for _, key := range deltas {
+			if path.Base(key) < cutoff {
+				_ = b.Delete(c.context, key)
+			}
+		}
+	}
+	return nil
+}
+
+// WriteManifestDelta records files added to the prefix, it does nothing until the prefix has a snapshot
+func (c *Client) WriteManifestDelta(base string, added []string) error {
+	if len(added) == 0 {
+		return nil
+	}
+	if generation, err := c.ManifestGeneration(base); err != nil || generation == 0 {
+		return err
+	}
+
+	content, err := json.Marshal(ManifestDelta{Added: added})
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("%s.d/%s-%s.json", base, deltaCursor(time.Now()), uuid.New().String())
+	return c.WriteFile(key, content, nil)
+}
+
+// listDeltas returns the keys of the deltas named after cursor
+func (c *Client) listDeltas(base, cursor string) ([]string, error) {
+	b, err := blob.OpenBucket(c.context, c.bucket)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = b.Close() }()
+
+	var out []string
+	iter := b.List(&blob.ListOptions{Prefix: base + ".d/"})
+	for {
+		obj, err := iter.Next(c.context)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return out, err
+		}
+		if !obj.IsDir && path.Base(obj.Key) > cursor {
+			out = append(out, obj.Key)
+		}
+	}
+	return out, nil
+}
+
+// MirrorLocalIncremental mirrors remotePrefix in localFolder like MirrorLocal, using the manifest at base instead of
+// listing the prefix. Only the deltas that haven't been applied yet are fetched, the snapshot only when it has been
+// replaced. The whole prefix is listed when there is no manifest or it is older than maxAge. The instance's
+// progress is kept in stateFile, it only moves forward once every file was downloaded.
+func (c *Client) MirrorLocalIncremental(remotePrefix, localFolder, base, stateFile string, maxAge time.Duration) (*TransferResult, error) {
+	var state ManifestState
+	if content, err := os.ReadFile(stateFile); err == nil {
+		_ = json.Unmarshal(content, &state)
+	}
+
+	generation, err := c.ManifestGeneration(base)
+	if err != nil {
+		return newTransferResult(), err
+	}
+
+	var expected map[string]bool
+	if generation != state.Generation {
+		var manifest Manifest
+		if generation > 0 {
+			if content, err := c.ReadFile(base+".json", nil); err == nil {
+				_ = json.Unmarshal(content, &manifest)
+			}
+		}
+		if manifest.Generation != generation || time.Since(manifest.Created) > maxAge {
+			return c.mirrorLocalFull(remotePrefix, localFolder, stateFile)
+		}
+
+		state = ManifestState{Generation: generation, Created: manifest.Created}
+		expected = make(map[string]bool)
+		for _, fn := range manifest.Files {
+			expected[filepath.Base(fn)] = true
+		}
+	} else if time.Since(state.Created) > maxAge {
+		return c.mirrorLocalFull(remotePrefix, localFolder, stateFile)
+	}
+
+	deltas, err := c.listDeltas(base, deltaCursor(state.Created.Add(-deltaWindow)))
+	if err != nil {
+		return newTransferResult(), err
+	}
+	if state.Applied == nil {
+		state.Applied = make(map[string]bool)
+	}
+	added := make(map[string]bool)
+	beforeSnapshot := make(map[string]bool)
+	for _, key := range deltas {
+		if state.Applied[path.Base(key)] {
+			continue
+		}
+		content, err := c.ReadFile(key, nil)
+		if err != nil {
+			return newTransferResult(), err
+		}
+		state.Applied[path.Base(key)] = true
+		var delta ManifestDelta
+		if err = json.Unmarshal(content, &delta); err != nil {
+			log.Printf("[!] Ignoring malformed manifest delta(%s): %s", key, err.Error())
+			continue
+		}
+		for _, fn := range delta.Added {
+			added[filepath.Base(fn)] = true
+			if path.Base(key) < deltaCursor(state.Created) {
+				beforeSnapshot[filepath.Base(fn)] = true
+			}
+		}
+	}
+	// Inputs added just before the snapshot but missing from it were either merged away or still uploading when the
+	// corpus was listed, only the bucket can tell which
+	for fn := range beforeSnapshot {
+		if expected == nil || expected[fn] {
+			continue
+		}
+		if _, err := c.FileInfo(path.Join(remotePrefix, fn)); gcerrors.Code(err) == gcerrors.NotFound {
+			delete(added, fn)
+		}
+	}
+
+	// Only a new snapshot can remove files, deltas just add to it
+	files, err := os.ReadDir(localFolder)
+	if err != nil {
+		return newTransferResult(), err
+	}
+	local := make(map[string]bool)
+	var toDelete []string
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		local[f.Name()] = true
+		if expected != nil && !expected[f.Name()] && !added[f.Name()] {
+			toDelete = append(toDelete, f.Name())
+		}
+	}
+	for fn := range expected {
+		added[fn] = true
+	}
+	var toDownload, skipped []string
+	for fn := range added {
+		if !local[fn] {
+			toDownload = append(toDownload, path.Join(remotePrefix, fn))
+		} else {
+			skipped = append(skipped, path.Join(remotePrefix, fn))
+		}
+	}
+
+	result, err := c.Download(toDownload, localFolder)
+	result.Skipped = skipped
+	// Deltas from before the snapshot can name inputs the merge has since removed
+	for key, downloadErr := range result.Failed {
+		if gcerrors.Code(downloadErr) == gcerrors.NotFound {
+			delete(result.Failed, key)
+			result.Skipped = append(result.Skipped, key)
+		}
+	}
+	var transferErr *TransferError
+	if errors.As(err, &transferErr) {
+		err = result.Err()
+	}
+	for _, fn := range toDelete {
+		if rmErr := os.Remove(filepath.Join(localFolder, fn)); rmErr == nil {
+			result.Deleted = append(result.Deleted, fn)
+		}
+	}
+
+	// The next sync goes through the same deltas again to retry the files that failed
+	if err != nil {
+		return result, err
+	}
+	if content, err := json.Marshal(state); err == nil {
+		_ = os.WriteFile(stateFile, content, 0660)
+	}
+	return result, nil
+}
+
+// mirrorLocalFull falls back to listing the whole prefix, the next sync starts from the latest snapshot again
+func (c *Client) mirrorLocalFull(remotePrefix, localFolder, stateFile string) (*TransferResult, error) {
+	log.Printf("[-] No current manifest for %s, listing the whole prefix", remotePrefix)
+	_ = os.Remove(stateFile)
+	return c.MirrorLocal(remotePrefix, localFolder)
+}
@@ -0,0 +1,95 @@
+package cloudutil
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestMirrorLocalIncremental(t *testing.T) {
+	bucket := t.TempDir()
+	local := t.TempDir()
+	state := filepath.Join(t.TempDir(), "corpus.manifest")
+	client := NewClient(context.Background(), "file://"+bucket)
+	upload := func(names ...string) {
+		for _, name := range names {
+			fn := filepath.Join(bucket, "campaign", "corpus", name)
+			_ = os.MkdirAll(filepath.Dir(fn), 0770)
+			if err := os.WriteFile(fn, []byte(name), 0660); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	sync := func(expected ...string) {
+		t.Helper()
+		if _, err := client.MirrorLocalIncremental("campaign/corpus", local, "campaign/manifests/corpus", state, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		entries, _ := os.ReadDir(local)
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		sort.Strings(names)
+		if len(names) != len(expected) {
+			t.Fatalf("expected %v, got %v", expected, names)
+		}
+		for i := range names {
+			if names[i] != expected[i] {
+				t.Fatalf("expected %v, got %v", expected, names)
+			}
+		}
+	}
+
+	// Without a manifest the whole corpus is listed
+	upload("a", "b")
+	sync("a", "b")
+
+	if err := client.WriteManifest("campaign/manifests/corpus", []string{"a", "b"}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	upload("c")
+	if err := client.WriteManifestDelta("campaign/manifests/corpus", []string{"c"}); err != nil {
+		t.Fatal(err)
+	}
+	// Objects missing from the manifest aren't seen, the corpus isn't listed anymore
+	upload("unlisted")
+	sync("a", "b", "c")
+
+	upload("d")
+	if err := client.WriteManifestDelta("campaign/manifests/corpus", []string{"d"}); err != nil {
+		t.Fatal(err)
+	}
+	sync("a", "b", "c", "d")
+
+	// A new snapshot removes the files that were merged away. "e" was still uploading when the merge listed the corpus,
+	// its delta is older than the snapshot but it isn't lost.
+	upload("e")
+	if err := client.WriteManifestDelta("campaign/manifests/corpus", []string{"e"}); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.Remove(filepath.Join(bucket, "campaign", "corpus", "a"))
+	_ = os.Remove(filepath.Join(bucket, "campaign", "corpus", "c"))
+	if err := client.WriteManifest("campaign/manifests/corpus", []string{"b", "d"}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	sync("b", "d", "e")
+
+	// A delta from a writer whose clock is behind sorts before the deltas already applied, it is still applied
+	upload("f")
+	late := fmt.Sprintf("campaign/manifests/corpus.d/%s-late.json", deltaCursor(time.Now().Add(-5*time.Minute)))
+	if err := client.WriteFile(late, []byte(`{"added":["f"]}`), nil); err != nil {
+		t.Fatal(err)
+	}
+	sync("b", "d", "e", "f")
+
+	// A stale manifest falls back to listing the corpus
+	if err := client.WriteManifest("campaign/manifests/corpus", []string{"b", "d"}, time.Now().Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	sync("b", "d", "e", "f", "unlisted")
+}
@@ -0,0 +1,73 @@
+package cloudutil
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TransferResult is the outcome of a batch of uploads or downloads. Keys are the objects' keys, Deleted holds the
+// keys a mirror removed from the bucket or the names of the files it removed locally.
+type TransferResult struct {
+	Succeeded []string
+	Failed    map[string]error
+	// Skipped are keys that didn't need to be transferred, such as files that already exist
+	Skipped []string
+	Deleted []string
+	mu      sync.Mutex
+}
+
+func newTransferResult() *TransferResult {
+	return &TransferResult{Failed: make(map[string]error)}
+}
+
+func (r *TransferResult) succeed(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Succeeded = append(r.Succeeded, key)
+}
+
+func (r *TransferResult) fail(key string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Failed[key] = err
+}
+
+// Complete is true when none of the transfers failed
+func (r *TransferResult) Complete() bool {
+	return len(r.Failed) == 0
+}
+
+// Err returns a TransferError for the failed transfers, nil if every transfer succeeded
+func (r *TransferResult) Err() error {
+	if r.Complete() {
+		return nil
+	}
+	return &TransferError{Failed: r.Failed, Total: len(r.Succeeded) + len(r.Failed)}
+}
+
+// TransferError aggregates the errors of the transfers that failed in a batch
+type TransferError struct {
+	Failed map[string]error
+	Total  int
+}
+
+func (e *TransferError) Error() string {
+	keys := make([]string, 0, len(e.Failed))
+	for key := range e.Failed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Only a few are listed, a failing bucket can fail thousands of corpus files for the same reason
+	var errs []string
+	for _, key := range keys {
+		if len(errs) == 3 {
+			errs = append(errs, fmt.Sprintf("and %d more", len(keys)-len(errs)))
+			break
+		}
+		errs = append(errs, fmt.Sprintf("%s: %s", key, e.Failed[key].Error()))
+	}
+	return fmt.Sprintf("%d of %d transfers failed (%s)", len(e.Failed), e.Total, strings.Join(errs, ", "))
+}
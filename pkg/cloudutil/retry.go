@@ -0,0 +1,70 @@
+package cloudutil
+
+import (
+	"errors"
+	"gocloud.dev/gcerrors"
+	"log"
+	"time"
+)
+
+const (
+	DefaultRetryAttempts = 5
+	DefaultRetryDelay    = 500 * time.Millisecond
+	maxRetryDelay        = 30 * time.Second
+)
+
+// permanentError wraps errors that won't go away by trying again, such as a missing local file
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+func permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// retryable reports whether an error from the bucket may be transient
+func retryable(err error) bool {
+	var p permanentError
+	if errors.As(err, &p) {
+		return false
+	}
+	switch gcerrors.Code(err) {
+	case gcerrors.NotFound, gcerrors.AlreadyExists, gcerrors.PermissionDenied, gcerrors.InvalidArgument,
+		gcerrors.FailedPrecondition, gcerrors.Unimplemented, gcerrors.Canceled:
+		return false
+	}
+	return true
+}
+
+// retry runs op until it succeeds, fails with an error that isn't retryable, or runs out of attempts. The delay
+// between attempts doubles each time and the client's context cuts it short.
+func (c *Client) retry(name string, op func() error) error {
+	delay := c.RetryDelay
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = op(); err == nil || !retryable(err) || attempt >= c.RetryAttempts {
+			return err
+		}
+		if c.context.Err() != nil {
+			return c.context.Err()
+		}
+
+		log.Printf("[-] Retrying %s in %s (attempt %d/%d): %s", name, delay, attempt+1, c.RetryAttempts, err.Error())
+		timer := time.NewTimer(delay)
+		select {
+		case <-c.context.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		if delay *= 2; delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package cloudutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	client := NewClient(context.Background(), "mem://")
+	client.RetryDelay = time.Millisecond
+
+	calls := 0
+	err := client.retry("transient", func() error {
+		if calls++; calls < 3 {
+			return errors.New("connection reset")
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("expected success on the third attempt, got %d calls: %v", calls, err)
+	}
+
+	calls = 0
+	_ = client.retry("exhausted", func() error { calls++; return errors.New("connection reset") })
+	if calls != DefaultRetryAttempts {
+		t.Errorf("expected %d attempts, got %d", DefaultRetryAttempts, calls)
+	}
+
+	calls = 0
+	_ = client.retry("permanent", func() error { calls++; return permanent(os.ErrNotExist) })
+	if calls != 1 {
+		t.Errorf("permanent errors shouldn't be retried, got %d calls", calls)
+	}
+
+	// A cancelled context cuts the backoff short
+	ctx, cancel := context.WithCancel(context.Background())
+	client = NewClient(ctx, "mem://")
+	client.RetryDelay = time.Hour
+	calls = 0
+	go func() { time.Sleep(10 * time.Millisecond); cancel() }()
+	start := time.Now()
+	_ = client.retry("cancelled", func() error { calls++; return errors.New("connection reset") })
+	if calls != 1 || time.Since(start) > time.Minute {
+		t.Errorf("expected the retry to stop when cancelled, got %d calls", calls)
+	}
+}
+
+func TestTransfers(t *testing.T) {
+	bucket := t.TempDir()
+	src := t.TempDir()
+	dst := t.TempDir()
+	client := NewClient(context.Background(), "file://"+bucket)
+	client.RetryDelay = time.Millisecond
+
+	content := bytes.Repeat([]byte("0123456789abcdef"), 256*1024)
+	if err := os.WriteFile(filepath.Join(src, "large"), content, 0660); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Upload(src, []string{"large"}, "corpus"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A missing object fails without leaving anything behind
+	result, err := client.Download([]string{"corpus/large", "corpus/missing"}, dst)
+	if err == nil || len(result.Succeeded) != 1 || result.Failed["corpus/missing"] == nil {
+		t.Fatalf("expected corpus/missing to fail, got %+v: %v", result, err)
+	}
+	entries, _ := os.ReadDir(dst)
+	if len(entries) != 1 || entries[0].Name() != "large" {
+		t.Fatalf("unexpected files after download: %v", entries)
+	}
+	if downloaded, _ := os.ReadFile(filepath.Join(dst, "large")); !bytes.Equal(downloaded, content) {
+		t.Errorf("downloaded content doesn't match the upload")
+	}
+
+	if err := client.DownloadSingle("corpus/missing", filepath.Join(dst, "single")); err == nil {
+		t.Errorf("expected an error downloading a missing object")
+	}
+	if _, err := os.Stat(filepath.Join(dst, "single")); !os.IsNotExist(err) {
+		t.Errorf("a failed download left a file behind")
+	}
+}
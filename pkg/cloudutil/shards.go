@@ -0,0 +1,415 @@
+package cloudutil
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gocloud.dev/blob"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A packed corpus is stored as shards, tar or zip archives named by the SHA-256 of their content. The authoritative
+// corpus is written as a set of shards by MirrorRemoteShards and anyone else adds small delta shards with
+// UploadShard. Shards are unpacked into a flat local folder so nothing else needs to know about them.
+//
+// Every shard is built the same way from the same files, so an unchanged set of files keeps its name and is not
+// uploaded again.
+
+// ShardIndex records the files unpacked from each shard, so a shard is only downloaded once and its files can be
+// removed when it is replaced
+type ShardIndex map[string][]string
+
+// zipEpoch is the modification time of every zip entry, zip can't represent times before 1980
+var zipEpoch = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func isShard(name string) bool {
+	return strings.HasSuffix(name, ".tar") || strings.HasSuffix(name, ".zip")
+}
+
+func loadShardIndex(fn string) ShardIndex {
+	index := make(ShardIndex)
+	if content, err := os.ReadFile(fn); err == nil {
+		_ = json.Unmarshal(content, &index)
+	}
+	return index
+}
+
+func (index ShardIndex) save(fn string) error {
+	content, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fn, content, 0660)
+}
+
+// ListShards returns the keys of the shards under prefix
+func (c *Client) ListShards(prefix string) ([]string, error) {
+	b, err := blob.OpenBucket(c.context, c.bucket)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = b.Close() }()
+
+	var out []string
+	iter := b.List(&blob.ListOptions{Prefix: prefix + "/"})
+	for {
+		obj, err := iter.Next(c.context)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return out, err
+		}
+		if !obj.IsDir && isShard(obj.Key) {
+			out = append(out, obj.Key)
+		}
+	}
+	return out, nil
+}
+
+// MirrorLocalShards mirrors the shards under prefix in localFolder like MirrorLocal does for single files. Only
+// shards missing from indexFile are downloaded, and local files not in any of the shards are deleted. The result's
+// keys are shards, except for Deleted which holds the local files removed.
+func (c *Client) MirrorLocalShards(prefix, localFolder, indexFile string) (*TransferResult, error) {
+	index := loadShardIndex(indexFile)
+	keys, err := c.ListShards(prefix)
+	if err != nil {
+		return newTransferResult(), err
+	}
+
+	result := newTransferResult()
+	remote := make(map[string]bool)
+	var toDownload []string
+	for _, key := range keys {
+		remote[path.Base(key)] = true
+		if _, found := index[path.Base(key)]; !found {
+			toDownload = append(toDownload, key)
+		} else {
+			result.Skipped = append(result.Skipped, key)
+		}
+	}
+	for name := range index {
+		if !remote[name] {
+			delete(index, name)
+		}
+	}
+
+	if len(toDownload) > 0 {
+		unpacked, err := c.DownloadShards(toDownload, localFolder)
+		for _, key := range toDownload {
+			if files, found := unpacked[path.Base(key)]; found {
+				index[path.Base(key)] = files
+				result.Succeeded = append(result.Succeeded, key)
+			}
+		}
+		var transferErr *TransferError
+		if errors.As(err, &transferErr) {
+			result.Failed = transferErr.Failed
+		} else if err != nil {
+			return result, err
+		}
+	}
+	if err = index.save(indexFile); err != nil {
+		return result, err
+	}
+
+	// Files only go once every shard is unpacked, a shard that failed to download may hold them
+	if result.Complete() {
+		keep := make(map[string]bool)
+		for _, files := range index {
+			for _, fn := range files {
+				keep[fn] = true
+			}
+		}
+		files, err := os.ReadDir(localFolder)
+		if err != nil {
+			return result, err
+		}
+		for _, f := range files {
+			if !f.IsDir() && !keep[f.Name()] {
+				if os.Remove(filepath.Join(localFolder, f.Name())) == nil {
+					result.Deleted = append(result.Deleted, f.Name())
+				}
+			}
+		}
+	}
+	return result, result.Err()
+}
+
+// DownloadShards downloads and unpacks the shards into localFolder, returning the files unpacked from each shard.
+// Shards that fail are left out and reported in a TransferError.
+func (c *Client) DownloadShards(keys []string, localFolder string) (ShardIndex, error) {
+	tmp, err := os.MkdirTemp(filepath.Dir(filepath.Clean(localFolder)), ".shards-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	downloaded, err := c.Download(keys, tmp)
+	if err != nil && downloaded.Complete() {
+		return nil, err
+	}
+	result := newTransferResult()
+	unpacked := make(ShardIndex)
+	for _, key := range keys {
+		if err, failed := downloaded.Failed[key]; failed {
+			result.fail(key, err)
+			continue
+		}
+		name := path.Base(key)
+		files, err := unpackShard(filepath.Join(tmp, name), localFolder)
+		if err != nil {
+			result.fail(key, errors.New("failed to unpack shard: "+err.Error()))
+			continue
+		}
+		result.succeed(key)
+		unpacked[name] = files
+	}
+	return unpacked, result.Err()
+}
+
+// UploadShard packs the files into a single shard and uploads it, the shard is added to indexFile so it isn't
+// downloaded again. The shard's key is returned.
+func (c *Client) UploadShard(localFolder string, files []string, prefix, format, indexFile string) (string, error) {
+	tmp, err := os.MkdirTemp(filepath.Dir(filepath.Clean(localFolder)), ".shards-")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	name, err := writeShard(tmp, localFolder, files, format)
+	if err != nil {
+		return "", err
+	}
+	if _, err = c.Upload(tmp, []string{name}, prefix); err != nil {
+		return "", err
+	}
+
+	index := loadShardIndex(indexFile)
+	index[name] = files
+	return path.Join(prefix, name), index.save(indexFile)
+}
+
+// MirrorRemoteShards makes the shards under prefix match localFolder. The files are packed into shards of about
+// maxShardSize bytes, shards that don't exist yet are uploaded and the other shards in replace are deleted. Shards
+// that aren't in replace were written after localFolder was put together and are kept, a nil replace replaces every
+// shard. Like MirrorRemote nothing is deleted unless every upload succeeded.
+func (c *Client) MirrorRemoteShards(localFolder, prefix, format string, maxShardSize int64, replace map[string]bool) (*TransferResult, error) {
+	entries, err := os.ReadDir(localFolder)
+	if err != nil {
+		return newTransferResult(), err
+	}
+
+	// Files are grouped in name order, which for content named inputs spreads them evenly
+	var groups [][]string
+	var current []string
+	var size int64
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() {
+			continue
+		}
+		if len(current) > 0 && size+info.Size() > maxShardSize {
+			groups = append(groups, current)
+			current, size = nil, 0
+		}
+		current = append(current, entry.Name())
+		size += info.Size()
+	}
+	if len(current) > 0 {
+		groups = append(groups, current)
+	}
+
+	tmp, err := os.MkdirTemp(filepath.Dir(filepath.Clean(localFolder)), ".shards-")
+	if err != nil {
+		return newTransferResult(), err
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	keys, err := c.ListShards(prefix)
+	if err != nil {
+		return newTransferResult(), err
+	}
+	existing := make(map[string]bool)
+	for _, key := range keys {
+		existing[path.Base(key)] = true
+	}
+
+	shards := make(map[string]bool)
+	var toUpload, skipped []string
+	for _, group := range groups {
+		name, err := writeShard(tmp, localFolder, group, format)
+		if err != nil {
+			return newTransferResult(), err
+		}
+		shards[name] = true
+		if !existing[name] {
+			toUpload = append(toUpload, name)
+		} else {
+			skipped = append(skipped, path.Join(prefix, name))
+		}
+	}
+	result, err := c.Upload(tmp, toUpload, prefix)
+	result.Skipped = skipped
+	if err != nil {
+		return result, err
+	}
+
+	b, err := blob.OpenBucket(c.context, c.bucket)
+	if err != nil {
+		return result, err
+	}
+	defer func() { _ = b.Close() }()
+	for _, key := range keys {
+		if !shards[path.Base(key)] && (replace == nil || replace[key]) {
+			if err := b.Delete(c.context, key); err != nil {
+				result.fail(key, err)
+				continue
+			}
+			result.Deleted = append(result.Deleted, key)
+		}
+	}
+	return result, result.Err()
+}
+
+// writeShard packs the files into a tar or zip archive in dir, named by the SHA-256 of the archive
+func writeShard(dir, localFolder string, files []string, format string) (string, error) {
+	if format == "" {
+		format = "tar"
+	} else if format != "tar" && format != "zip" {
+		return "", fmt.Errorf("unsupported shard format '%s'", format)
+	}
+	sorted := append([]string{}, files...)
+	sort.Strings(sorted)
+
+	fp, err := os.CreateTemp(dir, "shard-")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = fp.Close() }()
+	h := sha256.New()
+	out := io.MultiWriter(fp, h)
+
+	if format == "zip" {
+		archive := zip.NewWriter(out)
+		for _, fn := range sorted {
+			content, err := os.ReadFile(filepath.Join(localFolder, fn))
+			if err != nil {
+				return "", err
+			}
+			w, err := archive.CreateHeader(&zip.FileHeader{Name: fn, Method: zip.Deflate, Modified: zipEpoch})
+			if err != nil {
+				return "", err
+			}
+			if _, err = w.Write(content); err != nil {
+				return "", err
+			}
+		}
+		if err = archive.Close(); err != nil {
+			return "", err
+		}
+	} else {
+		archive := tar.NewWriter(out)
+		for _, fn := range sorted {
+			content, err := os.ReadFile(filepath.Join(localFolder, fn))
+			if err != nil {
+				return "", err
+			}
+			header := &tar.Header{Name: fn, Mode: 0644, Size: int64(len(content)), ModTime: time.Unix(0, 0), Format: tar.FormatPAX}
+			if err = archive.WriteHeader(header); err != nil {
+				return "", err
+			}
+			if _, err = archive.Write(content); err != nil {
+				return "", err
+			}
+		}
+		if err = archive.Close(); err != nil {
+			return "", err
+		}
+	}
+	if err = fp.Close(); err != nil {
+		return "", err
+	}
+
+	name := hex.EncodeToString(h.Sum(nil)) + "." + format
+	if err = os.Rename(fp.Name(), filepath.Join(dir, name)); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// unpackShard extracts every file in the shard into localFolder, the folder is flat so any directories in the
+// entries' names are dropped
+func unpackShard(archivePath, localFolder string) ([]string, error) {
+	var files []string
+	write := func(name string, reader io.Reader) error {
+		name = path.Base(filepath.ToSlash(name))
+		if name == "." || name == "/" || name == ".." {
+			return nil
+		}
+		fp, err := os.OpenFile(filepath.Join(localFolder, name), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0660)
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(fp, reader); err != nil {
+			_ = fp.Close()
+			return err
+		}
+		files = append(files, name)
+		return fp.Close()
+	}
+
+	if strings.HasSuffix(archivePath, ".zip") {
+		archive, err := zip.OpenReader(archivePath)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = archive.Close() }()
+		for _, f := range archive.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			reader, err := f.Open()
+			if err != nil {
+				return files, err
+			}
+			err = write(f.Name, reader)
+			_ = reader.Close()
+			if err != nil {
+				return files, err
+			}
+		}
+		return files, nil
+	}
+
+	fp, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = fp.Close() }()
+	archive := tar.NewReader(fp)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return files, nil
+		} else if err != nil {
+			return files, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err = write(header.Name, archive); err != nil {
+			return files, err
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package cloudutil
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestShards(t *testing.T) {
+	bucket := t.TempDir()
+	client := NewClient(context.Background(), "file://"+bucket)
+	write := func(dir string, names ...string) {
+		for _, name := range names {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte("input "+name), 0660); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	expectFiles := func(dir string, expected ...string) {
+		t.Helper()
+		entries, _ := os.ReadDir(dir)
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		sort.Strings(names)
+		if len(names) != len(expected) {
+			t.Fatalf("expected %v, got %v", expected, names)
+		}
+		for i := range names {
+			if names[i] != expected[i] {
+				t.Fatalf("expected %v, got %v", expected, names)
+			}
+		}
+	}
+
+	for _, format := range []string{"tar", "zip"} {
+		t.Run(format, func(t *testing.T) {
+			prefix := "campaign-" + format + "/shards"
+			merged := filepath.Join(t.TempDir(), "corpus")
+			local := filepath.Join(t.TempDir(), "corpus")
+			index := filepath.Join(filepath.Dir(local), "corpus.shards")
+			_ = os.MkdirAll(merged, 0770)
+			_ = os.MkdirAll(local, 0770)
+
+			// Each shard holds two of the 8 byte inputs
+			write(merged, "a", "b", "c")
+			if result, err := client.MirrorRemoteShards(merged, prefix, format, 16, nil); err != nil || len(result.Succeeded) != 2 {
+				t.Fatalf("expected 2 shards, got %v: %v", result.Succeeded, err)
+			}
+			// Shards are content addressed, writing the same files again uploads nothing
+			if result, err := client.MirrorRemoteShards(merged, prefix, format, 16, nil); err != nil || len(result.Succeeded) != 0 || len(result.Skipped) != 2 || len(result.Deleted) != 0 {
+				t.Fatalf("expected no changes, got %+v: %v", result, err)
+			}
+
+			write(local, "stale")
+			if result, err := client.MirrorLocalShards(prefix, local, index); err != nil || len(result.Succeeded) != 2 || len(result.Deleted) != 1 {
+				t.Fatalf("unexpected mirror %+v: %v", result, err)
+			}
+			expectFiles(local, "a", "b", "c")
+			if content, _ := os.ReadFile(filepath.Join(local, "b")); string(content) != "input b" {
+				t.Errorf("unexpected content %q", content)
+			}
+
+			// A delta shard from this instance is already unpacked so it isn't downloaded again
+			write(local, "d")
+			if _, err := client.UploadShard(local, []string{"d"}, prefix, format, index); err != nil {
+				t.Fatal(err)
+			}
+			if result, err := client.MirrorLocalShards(prefix, local, index); err != nil || len(result.Succeeded) != 0 || len(result.Deleted) != 0 {
+				t.Fatalf("unexpected mirror %+v: %v", result, err)
+			}
+
+			// Merging the corpus replaces the shards, the delta shard holding only "d" is rebuilt identically and kept
+			_ = os.Remove(filepath.Join(merged, "a"))
+			write(merged, "d")
+			if result, err := client.MirrorRemoteShards(merged, prefix, format, 16, nil); err != nil || len(result.Deleted) != 2 {
+				t.Fatalf("expected the old shards to be deleted, got %v: %v", result.Deleted, err)
+			}
+			if _, err := client.MirrorLocalShards(prefix, local, index); err != nil {
+				t.Fatal(err)
+			}
+			expectFiles(local, "b", "c", "d")
+
+			// A shard uploaded while the corpus was merged isn't part of it and is kept
+			listed, _ := client.ListShards(prefix)
+			replace := make(map[string]bool)
+			for _, key := range listed {
+				replace[key] = true
+			}
+			write(local, "e")
+			if _, err := client.UploadShard(local, []string{"e"}, prefix, format, index); err != nil {
+				t.Fatal(err)
+			}
+			_ = os.Remove(filepath.Join(merged, "b"))
+			if _, err := client.MirrorRemoteShards(merged, prefix, format, 16, replace); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := client.MirrorLocalShards(prefix, local, index); err != nil {
+				t.Fatal(err)
+			}
+			expectFiles(local, "c", "d", "e")
+		})
+	}
+}
@@ -1,84 +1,105 @@
 package cloudutil
 
 import (
+	"context"
 	"gocloud.dev/blob"
 	_ "gocloud.dev/blob/fileblob"
 	_ "gocloud.dev/blob/gcsblob"
 	_ "gocloud.dev/blob/memblob"
 	_ "gocloud.dev/blob/s3blob"
+	"gocloud.dev/gcerrors"
 	"io"
 	"log"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
-func (c *Client) UploadIfNotExist(localFolder string, files []string, prefix string) error {
+func (c *Client) UploadIfNotExist(localFolder string, files []string, prefix string) (*TransferResult, error) {
 	b, err := blob.OpenBucket(c.context, c.bucket)
 	if err != nil {
-		return err
+		return newTransferResult(), err
 	}
 
-	var newFiles []string
+	var newFiles, skipped []string
 	for _, fn := range files {
 		key := path.Join(prefix, fn)
 		if exists, _ := b.Exists(c.context, key); !exists {
 			newFiles = append(newFiles, fn)
+		} else {
+			skipped = append(skipped, key)
 		}
 	}
-	return c.Upload(localFolder, newFiles, prefix)
+	result, err := c.Upload(localFolder, newFiles, prefix)
+	result.Skipped = append(result.Skipped, skipped...)
+	return result, err
 }
 
-func (c *Client) uploadFile(b *blob.Bucket, key, localFn string) {
-	defer c.wg.Done()
+func (c *Client) uploadFile(b *blob.Bucket, key, localFn string, result *TransferResult) {
 	if err := c.sema.Acquire(c.context, 1); err != nil {
-		log.Printf("[!] failed to acquire semaphore(download: %s): %s", key, err.Error())
+		log.Printf("[!] failed to acquire semaphore(upload: %s): %s", key, err.Error())
+		result.fail(key, err)
 		return
 	}
 	defer c.sema.Release(1)
 
-	if _, err := os.Stat(localFn); err != nil {
+	if err := c.retry("upload "+key, func() error { return c.upload(b, key, localFn) }); err != nil {
 		log.Printf("[!] Failed to upload(%s): %s", localFn, err.Error())
+		result.fail(key, err)
 		return
 	}
+	result.succeed(key)
+}
 
-	data, err := os.ReadFile(localFn)
+// upload streams localFn to key. The write is cancelled when it fails, so a partial object is never committed.
+func (c *Client) upload(b *blob.Bucket, key, localFn string) error {
+	fp, err := os.Open(localFn)
 	if err != nil {
-		log.Printf("[!] Failed to read upload target(%s): %s", localFn, err.Error())
-		return
+		return permanent(err)
 	}
+	defer func() { _ = fp.Close() }()
 
-	writer, err := b.NewWriter(c.context, key, nil)
+	ctx, cancel := context.WithCancel(c.context)
+	defer cancel()
+	writer, err := b.NewWriter(ctx, key, nil)
 	if err != nil {
-		log.Printf("[!] failed to upload(%s): %s", key, err.Error())
-		return
+		return err
 	}
-	_, _ = writer.Write(data)
-	err = writer.Close()
-	if err != nil {
-		log.Printf("[!] upload write failed(%s): %s", key, err.Error())
-		return
+	if _, err = io.Copy(writer, fp); err != nil {
+		cancel()
+		_ = writer.Close()
+		return err
 	}
+	return writer.Close()
 }
 
-func (c *Client) Upload(localFolder string, files []string, prefix string) error {
+// Upload uploads the files in localFolder under prefix. Every file is attempted, the result records which ones failed
+// and the returned error aggregates their errors.
+func (c *Client) Upload(localFolder string, files []string, prefix string) (*TransferResult, error) {
+	result := newTransferResult()
 	b, err := blob.OpenBucket(c.context, c.bucket)
 	if err != nil {
-		return err
+		return result, err
 	}
 
+	// The client is shared between goroutines, so each call only waits for its own transfers
 	localFolder, _ = filepath.Abs(localFolder)
-	c.wg.Add(len(files))
+	var wg sync.WaitGroup
+	wg.Add(len(files))
 	for _, fn := range files {
 		localFn := filepath.Join(localFolder, fn)
 		key := path.Join(prefix, fn)
-		go c.uploadFile(b, key, localFn)
+		go func() {
+			defer wg.Done()
+			c.uploadFile(b, key, localFn, result)
+		}()
 	}
-	c.wg.Wait()
+	wg.Wait()
 	log.Println("[-] Upload finished")
-	return nil
+	return result, result.Err()
 }
 
 func (c *Client) DownloadSingle(key string, localFile string) error {
@@ -86,64 +107,101 @@ func (c *Client) DownloadSingle(key string, localFile string) error {
 	if err != nil {
 		return err
 	}
+	defer func() { _ = b.Close() }()
 
-	reader, err := b.NewReader(c.context, key, nil)
-	if err != nil {
-		return err
-	}
-
-	if fp, err := os.OpenFile(localFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0770); err != nil {
-		log.Printf("[!] failed to open(%s): %s", key, err.Error())
-	} else {
-		if _, err = reader.WriteTo(fp); err != nil {
-			log.Printf("[!] failed to write: %s", err.Error())
-		}
-		_ = fp.Close()
-	}
-	_ = reader.Close()
-	return nil
-
+	return c.retry("download "+key, func() error { return c.download(b, key, localFile, 0770) })
 }
 
-func (c *Client) downloadFile(b *blob.Bucket, key, localFn string) {
-	defer c.wg.Done()
+func (c *Client) downloadFile(b *blob.Bucket, key, localFn string, result *TransferResult) {
 	if err := c.sema.Acquire(c.context, 1); err != nil {
 		log.Printf("[!] failed to acquire semaphore(download: %s): %s", key, err.Error())
+		result.fail(key, err)
 		return
 	}
 	defer c.sema.Release(1)
 
+	if err := c.retry("download "+key, func() error { return c.download(b, key, localFn, 0660) }); err != nil {
+		log.Printf("[!] failed to download(%s): %s", key, err.Error())
+		result.fail(key, err)
+		return
+	}
+	result.succeed(key)
+}
+
+// download streams key into a temporary file next to localFn and renames it into place once it is complete, so a
+// failed transfer never leaves a truncated file behind
+func (c *Client) download(b *blob.Bucket, key, localFn string, mode os.FileMode) error {
 	reader, err := b.NewReader(c.context, key, nil)
 	if err != nil {
-		log.Printf("[!] failed to open reader(%s): %s", key, err.Error())
-		return
+		return err
 	}
 	defer func() { _ = reader.Close() }()
 
-	if fp, err := os.OpenFile(localFn, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0660); err != nil {
-		log.Printf("[!] failed to open(%s): %s", key, err.Error())
-	} else {
-		if _, err = reader.WriteTo(fp); err != nil {
-			log.Printf("[!] failed to write: %s", err.Error())
-		}
+	fp, err := os.CreateTemp(filepath.Dir(localFn), ".download-")
+	if err != nil {
+		return permanent(err)
+	}
+	defer func() { _ = os.Remove(fp.Name()) }()
+
+	if _, err = io.Copy(fp, reader); err != nil {
 		_ = fp.Close()
+		return err
+	}
+	if err = fp.Close(); err != nil {
+		return permanent(err)
 	}
+	if err = os.Chmod(fp.Name(), mode); err != nil {
+		return permanent(err)
+	}
+	return permanent(os.Rename(fp.Name(), localFn))
 }
 
-func (c *Client) Download(keys []string, localFolder string) error {
+// Download downloads the keys into localFolder. Like Upload every key is attempted, the result records which ones
+// failed and the returned error aggregates their errors.
+func (c *Client) Download(keys []string, localFolder string) (*TransferResult, error) {
+	return c.DownloadAs(keys, localFolder, filepath.Base)
+}
+
+// DownloadAs downloads the keys into the local folder like Download, naming each local file with name(key) instead
+// of the key's base name, for keys whose base names aren't unique
+func (c *Client) DownloadAs(keys []string, localFolder string, name func(key string) string) (*TransferResult, error) {
+	result := newTransferResult()
 	b, err := blob.OpenBucket(c.context, c.bucket)
 	if err != nil {
-		return err
+		return result, err
 	}
 
 	localFolder, _ = filepath.Abs(localFolder)
-	c.wg.Add(len(keys))
+	var wg sync.WaitGroup
+	wg.Add(len(keys))
 	for _, key := range keys {
-		localFn, _ := filepath.Abs(filepath.Join(localFolder, filepath.Base(key)))
-		go c.downloadFile(b, key, localFn)
+		localFn, _ := filepath.Abs(filepath.Join(localFolder, name(key)))
+		go func(key string) {
+			defer wg.Done()
+			c.downloadFile(b, key, localFn, result)
+		}(key)
+	}
+	wg.Wait()
+	return result, result.Err()
+}
+
+// Delete removes the keys from the bucket
+func (c *Client) Delete(keys []string) (*TransferResult, error) {
+	result := newTransferResult()
+	b, err := blob.OpenBucket(c.context, c.bucket)
+	if err != nil {
+		return result, err
 	}
-	c.wg.Wait()
-	return nil
+	defer func() { _ = b.Close() }()
+
+	for _, key := range keys {
+		if err := b.Delete(c.context, key); err != nil && gcerrors.Code(err) != gcerrors.NotFound {
+			result.fail(key, err)
+			continue
+		}
+		result.Deleted = append(result.Deleted, key)
+	}
+	return result, result.Err()
 }
 
 // FileInfo uses the storage library to retrieve the object's attribute
@@ -156,6 +214,41 @@ func (c *Client) FileInfo(key string) (*blob.Attributes, error) {
 	return b.Attributes(c.context, key)
 }
 
+// Exists returns true if the object exists
+func (c *Client) Exists(key string) (bool, error) {
+	b, err := blob.OpenBucket(c.context, c.bucket)
+	if err != nil {
+		return false, err
+	}
+
+	return b.Exists(c.context, key)
+}
+
+// ListDirectories returns the names of the directories directly under the prefix, the objects within them aren't
+// listed. The prefix should end with a `/`.
+func (c *Client) ListDirectories(prefix string) ([]string, error) {
+	b, err := blob.OpenBucket(c.context, c.bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	iter := b.List(&blob.ListOptions{Prefix: prefix, Delimiter: "/"})
+	var out []string
+	for {
+		obj, err := iter.Next(c.context)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return out, err
+		}
+		if obj.IsDir {
+			out = append(out, strings.TrimSuffix(strings.TrimPrefix(obj.Key, prefix), "/"))
+		}
+	}
+	return out, nil
+}
+
 func (c *Client) ReadFile(key string, opts *blob.ReaderOptions) ([]byte, error) {
 	b, err := blob.OpenBucket(c.context, c.bucket)
 	if err != nil {
@@ -176,28 +269,20 @@ func (c *Client) WriteFile(key string, buf []byte, opts *blob.WriterOptions) err
 	if err != nil {
 		return err
 	}
+	defer func() { _ = b.Close() }()
 
-	writer, err := b.NewWriter(c.context, key, opts)
-	if err != nil {
-		return err
-	}
-	defer func() { _ = writer.Close() }()
-
-	if _, err = writer.Write(buf); err != nil {
-		return err
-	}
-	err = writer.Close()
-	return err
+	return c.retry("write "+key, func() error { return b.WriteAll(c.context, key, buf, opts) })
 }
 
-// NewObjects returns a list of new objects in the location since a given timestamp
+// NewObjects returns a list of new objects under the prefix since a given timestamp. Only the prefix is listed, not
+// the whole bucket, so it stays cheap next to a large per-input corpus.
 func (c *Client) NewObjects(prefix string, since time.Time) ([]*blob.ListObject, error) {
 	b, err := blob.OpenBucket(c.context, c.bucket)
 	if err != nil {
 		return nil, err
 	}
 
-	iter := b.List(nil)
+	iter := b.List(&blob.ListOptions{Prefix: prefix})
 	var out []*blob.ListObject
 	for {
 		obj, err := iter.Next(c.context)
@@ -208,10 +293,8 @@ func (c *Client) NewObjects(prefix string, since time.Time) ([]*blob.ListObject,
 			return out, err
 		}
 
-		if prefix == "" || strings.HasPrefix(obj.Key, prefix) {
-			if !obj.IsDir && obj.ModTime.After(since) {
-				out = append(out, obj)
-			}
+		if !obj.IsDir && obj.ModTime.After(since) {
+			out = append(out, obj)
 		}
 	}
 	return out, nil
@@ -220,15 +303,15 @@ func (c *Client) NewObjects(prefix string, since time.Time) ([]*blob.ListObject,
 // MirrorLocal mirrors the remotePrefix in localFolder, this can delete files from localFolder
 // Any folders under the prefix will be flattened, and if a file already exists it is simply
 // not downloaded, there is no checksum or mtime comparison
-func (c *Client) MirrorLocal(remotePrefix, localFolder string) (int, int, error) {
+func (c *Client) MirrorLocal(remotePrefix, localFolder string) (*TransferResult, error) {
 	b, err := blob.OpenBucket(c.context, c.bucket)
 	if err != nil {
-		return -1, -1, err
+		return newTransferResult(), err
 	}
 
 	// Find all the files in remote not in local
 	remoteFiles := make(map[string]bool)
-	var toDownload []string
+	var toDownload, skipped []string
 
 	iter := b.List(&blob.ListOptions{Prefix: remotePrefix})
 	for {
@@ -236,7 +319,11 @@ func (c *Client) MirrorLocal(remotePrefix, localFolder string) (int, int, error)
 		if err == io.EOF {
 			break
 		}
-		if err != nil || obj.IsDir {
+		// A partial listing would delete local files that are still in the bucket
+		if err != nil {
+			return newTransferResult(), err
+		}
+		if obj.IsDir {
 			continue
 		}
 
@@ -245,6 +332,8 @@ func (c *Client) MirrorLocal(remotePrefix, localFolder string) (int, int, error)
 		if _, err := os.Stat(localFn); os.IsNotExist(err) {
 			// We don't have this file so download
 			toDownload = append(toDownload, obj.Key)
+		} else {
+			skipped = append(skipped, obj.Key)
 		}
 	}
 
@@ -252,7 +341,7 @@ func (c *Client) MirrorLocal(remotePrefix, localFolder string) (int, int, error)
 	var toDelete []string
 	files, err := os.ReadDir(localFolder)
 	if err != nil {
-		return -1, -1, err
+		return newTransferResult(), err
 	}
 	for _, f := range files {
 		if !f.IsDir() {
@@ -263,38 +352,40 @@ func (c *Client) MirrorLocal(remotePrefix, localFolder string) (int, int, error)
 	}
 
 	// Perform the actions...
-	if len(toDownload) > 0 {
-		_ = c.Download(toDownload, localFolder)
-	}
-
-	if len(toDelete) > 0 {
-		for _, fn := range toDelete {
-			_ = os.Remove(filepath.Join(localFolder, fn))
+	result, err := c.Download(toDownload, localFolder)
+	result.Skipped = skipped
+	for _, fn := range toDelete {
+		if rmErr := os.Remove(filepath.Join(localFolder, fn)); rmErr == nil {
+			result.Deleted = append(result.Deleted, fn)
 		}
 	}
 
-	return len(toDownload), len(toDelete), nil
+	return result, err
 }
 
 // MirrorRemote will make the remote prefix match the local folder including deleting files from remote
-func (c *Client) MirrorRemote(localFolder, remotePrefix string) (int, int, error) {
+// Nothing is deleted unless every upload succeeded, so an incomplete upload never loses files from the bucket.
+func (c *Client) MirrorRemote(localFolder, remotePrefix string) (*TransferResult, error) {
 	b, err := blob.OpenBucket(c.context, c.bucket)
 	if err != nil {
-		return -1, -1, err
+		return newTransferResult(), err
 	}
 
 	// Find all the files in local but not in remote
-	var toUpload []string
+	var toUpload, skipped []string
 	localFiles := make(map[string]bool)
 	files, err := os.ReadDir(localFolder)
 	if err != nil {
-		return -1, -1, err
+		return newTransferResult(), err
 	}
 	for _, f := range files {
 		if !f.IsDir() {
 			localFiles[f.Name()] = true
-			if exists, _ := b.Exists(c.context, path.Join(remotePrefix, f.Name())); !exists {
+			key := path.Join(remotePrefix, f.Name())
+			if exists, _ := b.Exists(c.context, key); !exists {
 				toUpload = append(toUpload, f.Name())
+			} else {
+				skipped = append(skipped, key)
 			}
 		}
 	}
@@ -307,7 +398,10 @@ func (c *Client) MirrorRemote(localFolder, remotePrefix string) (int, int, error
 		if err == io.EOF {
 			break
 		}
-		if err != nil || obj.IsDir {
+		if err != nil {
+			return newTransferResult(), err
+		}
+		if obj.IsDir {
 			continue
 		}
 		if _, found := localFiles[filepath.Base(obj.Key)]; !found {
@@ -316,10 +410,19 @@ func (c *Client) MirrorRemote(localFolder, remotePrefix string) (int, int, error
 	}
 
 	// Perform the actions
-	_ = c.Upload(localFolder, toUpload, remotePrefix)
+	result, err := c.Upload(localFolder, toUpload, remotePrefix)
+	result.Skipped = skipped
+	if err != nil {
+		log.Printf("[!] Upload to %s was incomplete, not deleting %d remote files", remotePrefix, len(toDelete))
+		return result, err
+	}
 	for _, key := range toDelete {
-		_ = b.Delete(c.context, key)
+		if err := b.Delete(c.context, key); err != nil {
+			result.fail(key, err)
+			continue
+		}
+		result.Deleted = append(result.Deleted, key)
 	}
 
-	return len(toUpload), len(toDelete), nil
+	return result, result.Err()
 }
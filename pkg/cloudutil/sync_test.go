@@ -0,0 +1,93 @@
+package cloudutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMirrorRemoteIncompleteUpload(t *testing.T) {
+	bucket := t.TempDir()
+	local := t.TempDir()
+	client := NewClient(context.Background(), "file://"+bucket)
+	client.RetryDelay = time.Millisecond
+
+	_ = os.MkdirAll(filepath.Join(bucket, "corpus"), 0770)
+	_ = os.WriteFile(filepath.Join(bucket, "corpus", "old"), []byte("old"), 0660)
+	_ = os.WriteFile(filepath.Join(local, "new"), []byte("new"), 0660)
+	// Listed like any other input but can't be read
+	if err := os.Symlink(filepath.Join(local, "missing"), filepath.Join(local, "broken")); err != nil {
+		t.Skip("symlinks are unsupported")
+	}
+
+	result, err := client.MirrorRemote(local, "corpus")
+	var transferErr *TransferError
+	if !errors.As(err, &transferErr) || transferErr.Failed["corpus/broken"] == nil {
+		t.Fatalf("expected the broken input to fail, got %v", err)
+	}
+	if len(result.Succeeded) != 1 || result.Succeeded[0] != "corpus/new" || len(result.Deleted) != 0 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if _, err = os.Stat(filepath.Join(bucket, "corpus", "old")); err != nil {
+		t.Errorf("remote corpus was deleted after an incomplete upload: %v", err)
+	}
+
+	// Once everything uploads the remote corpus is mirrored
+	_ = os.Remove(filepath.Join(local, "broken"))
+	if result, err = client.MirrorRemote(local, "corpus"); err != nil || len(result.Deleted) != 1 || len(result.Skipped) != 1 {
+		t.Fatalf("unexpected result %+v: %v", result, err)
+	}
+}
+
+func TestConcurrentUploads(t *testing.T) {
+	bucket := t.TempDir()
+	local := t.TempDir()
+	client := NewClient(context.Background(), "file://"+bucket)
+
+	var files []string
+	for i := 0; i < 20; i++ {
+		fn := fmt.Sprintf("input-%d", i)
+		_ = os.WriteFile(filepath.Join(local, fn), []byte(fn), 0660)
+		files = append(files, fn)
+	}
+
+	// Calls sharing a client, like crash reporting and the session's uploads, don't wait on each other's transfers
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, err := client.Upload(local, files, fmt.Sprintf("upload-%d", i))
+			if err != nil || len(result.Succeeded) != len(files) {
+				t.Errorf("upload %d incomplete: %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestNewObjects(t *testing.T) {
+	bucket := t.TempDir()
+	client := NewClient(context.Background(), "file://"+bucket)
+
+	for _, fn := range []string{"campaign/targets/a/fuzzer", "campaign/targets-old", "campaign/corpus/input"} {
+		_ = os.MkdirAll(filepath.Join(bucket, filepath.Dir(fn)), 0770)
+		_ = os.WriteFile(filepath.Join(bucket, fn), []byte(fn), 0660)
+	}
+
+	objects, err := client.NewObjects("campaign/targets/", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || objects[0].Key != "campaign/targets/a/fuzzer" {
+		t.Errorf("unexpected objects %v", objects)
+	}
+	if objects, _ = client.NewObjects("campaign/targets/", time.Now().Add(time.Hour)); len(objects) != 0 {
+		t.Errorf("expected no new objects, got %d", len(objects))
+	}
+}
@@ -2,16 +2,38 @@ package config
 
 type CampaignConfig struct {
 	// ID should be filesystem safe as it is used to find the work directory
-	Id                string
-	ReportingEndpoint string
-	CloudStorage      CloudStorageConfig
-	MaxTotalTime      int
-	IncludeHostEnv    bool
-	Arguments         []string
-	Environment       []string
-	UploadOnlyCrashes bool
-	MergeInterval     int
-	Weight            int
+	Id                   string
+	ReportingEndpoint    string
+	CloudStorage         CloudStorageConfig
+	Engine               string
+	FuzzFunction         string
+	EntryPoint           string
+	MaxTotalTime         int
+	IncludeHostEnv       bool
+	Arguments            []string
+	Environment          []string
+	UploadOnlyCrashes    bool
+	UpdateCheckInterval  int
+	MergeInterval        int
+	CorpusManifest       bool
+	ManifestMaxAgeHours  int
+	CorpusShards         bool
+	ShardFormat          string
+	MaxShardSizeMB       int
+	Weight               int
+	DedupCrashes         bool
+	DedupStackDepth      int
+	ReproduceCrashes     bool
+	ReproduceRuns        int
+	SkipNonReproducible  bool
+	MinimizeCrashes      bool
+	MinimizeRuns         int
+	MinimizeMaxTotalTime int
+	CgroupMemoryMaxMB    int
+	CgroupCPUs           float64
+	CgroupPidsMax        int
+	Sandbox              bool
+	SandboxWritablePaths []string
 }
 
 type HostConfig struct {
@@ -19,6 +41,11 @@ type HostConfig struct {
 	MaxJobCount     int
 	WorkDirectory   string
 	EnableMergeTask bool
+	TrustedKeys     []string
+	CgroupParent    string
+	// RetentionMaxAgeHours and RetentionMaxSizeMB are applied to each campaign's work directory
+	RetentionMaxAgeHours int
+	RetentionMaxSizeMB   int
 }
 
 type MultiConfig struct {
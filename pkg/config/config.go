@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"time"
 )
 
 type CloudStorageConfig struct {
@@ -15,7 +16,16 @@ type CloudStorageConfig struct {
 }
 
 type FuzzerConfig struct {
-	// ForkCount is the argument to -fork=N, core count is a good starting place for this value
+	// Engine is the fuzzing engine the target binary was built for, `libfuzzer` (default), `aflplusplus`,
+	// `honggfuzz` or `go` for a Go test binary built with `go test -c`
+	Engine string
+	// EntryPoint is the path of the target binary within a target bundle, defaults to `fuzzer`
+	EntryPoint string
+	// FuzzFunction is the name of the fuzz function to run with the `go` engine (ex. `FuzzParse`)
+	FuzzFunction string
+	// ForkCount is the argument to -fork=N, core count is a good starting place for this value. For AFL++ this is the
+	// total number of afl-fuzz instances (one main and ForkCount-1 secondaries), for honggfuzz it is `--threads`
+	// and for Go it is `-test.parallel`
 	ForkCount int
 	// MaxTotalTime represents the `-max_total_time` argument
 	MaxTotalTime int
@@ -27,6 +37,10 @@ type FuzzerConfig struct {
 	// Environment these are passed in regardless of the `IncludeHostEnv` value. You can use this to overwrite any
 	// existing environment vars or add some new ones
 	Environment []string
+	// UpdateCheckInterval is the number of seconds between checks for a new target binary while fuzzing. When one is
+	// found the fuzzer is stopped, its progress uploaded and it is restarted with the new binary for the rest of the
+	// session. Defaults to 300 seconds, a negative value disables the check.
+	UpdateCheckInterval int
 	// UploadOnlyCrashes libFuzzer will report on other types of issues like slow runs and oom. You may want to
 	// ignore those and only upload `crash-*` artifacts. Enable this to do so.
 	UploadOnlyCrashes bool
@@ -37,12 +51,22 @@ type Config struct {
 	InstanceId string
 	// WorkDirectory is a directory for any files the instance needs to store namely corpus, artifacts and logs
 	WorkDirectory string
+	// Target is the name of the target a configuration was derived for with ForTarget, it is empty for the campaign
+	Target string `json:"-"`
 	// ReportingEndpoint is an optional location for reporting crashes. A multipart/form-data POST request will be made
 	// to this endpoint with two fields, `log` and `artifact` containing the entirety of the log file and crash artifact.
 	ReportingEndpoint string
 	// InitScript should be the full path to an executable. Can use this to do any init work like setting up default auth
 	// the first and only argument is the configuration filename
 	InitScript string
+	// TrustedKeys are base64 encoded ed25519 public keys. When set, a target binary is only used if it comes with a
+	// `.sig` object signed by one of these keys. Its first line is a JSON manifest, `{"key": ..., "sha256": ...}`,
+	// naming the binary's full object key and hex encoded SHA-256, the second line the base64 encoded signature of the
+	// first.
+	TrustedKeys []string
+	// ShutdownGracePeriod is the number of seconds given to finish up after a SIGINT/SIGTERM. libFuzzer is interrupted
+	// and the results of the current run are uploaded before exiting. Defaults to 30 seconds.
+	ShutdownGracePeriod int
 	// CloudStorage contains all the URLs for cloud locations. The Paths should be relative to the root of the bucket. eg `example-campaign/corpus`
 	CloudStorage CloudStorageConfig
 	// Fuzzer is all the configuration options for Fuzz jobs
@@ -56,6 +80,139 @@ type Config struct {
 		// attempt a merge at a time.
 		Interval int
 	}
+	// CrashDedup controls deduplication of crash reports based on the sanitizer report in the log
+	CrashDedup struct {
+		// Enabled will keep a registry of crash signatures in the bucket under `signatures/`. Only the first
+		// occurrence of a signature is sent to the ReportingEndpoint, later ones just increment its counter.
+		Enabled bool
+		// StackDepth is the number of symbolized frames (along with the crash type) used to compute the signature.
+		// Defaults to 5 when not set.
+		StackDepth int
+	}
+	// Reproduce controls re-running crash artifacts to classify them as reliable, flaky or non-reproducible
+	Reproduce struct {
+		// Enabled will reproduce crashes before they are reported, adding a `verdict` field to the report, and
+		// runs the reproduce task that records the verdict in the metadata of artifacts this instance uploaded.
+		Enabled bool
+		// Runs is the number of times each artifact is run against the target binary (default: 5)
+		Runs int
+		// SkipNonReproducible will not report crashes that did not reproduce in any of the runs
+		SkipNonReproducible bool
+	}
+	// CorpusManifest syncs the corpus incrementally. The merge task writes a manifest of the corpus and instances add
+	// the files they upload to it, so each instance only fetches what changed since its last sync instead of
+	// listing the whole corpus. It needs the merge task to be enabled on at least one instance.
+	CorpusManifest struct {
+		// Enabled reads and updates the manifest, instances without it keep listing the corpus
+		Enabled bool
+		// MaxAgeHours is how old the manifest can be before it is considered stale and the whole corpus is listed
+		// instead (default: 24)
+		MaxAgeHours int
+	}
+	// CorpusShards stores the corpus as shards, tar or zip archives under `shards/` named by their SHA-256, instead
+	// of an object per input. The merge task writes the merged corpus as shards and instances upload their new
+	// inputs as small delta shards. Shards are unpacked into the local corpus directory. It takes precedence over
+	// CorpusManifest.
+	CorpusShards struct {
+		// Enabled reads and writes the corpus as shards, until the first shard is written the per-input corpus is used
+		Enabled bool
+		// Format of the shards written, `tar` (default) or `zip`
+		Format string
+		// MaxShardSizeMB is the size of the shards written by the merge task (default: 64)
+		MaxShardSizeMB int
+	}
+	// Retention prunes old logs and artifacts from the work directory, files are only removed once they have been
+	// uploaded to the bucket. Both limits can be used together.
+	Retention struct {
+		// MaxAgeHours removes logs and artifacts older than this many hours, 0 keeps them regardless of age
+		MaxAgeHours int
+		// MaxSizeMB is the total size logs and artifacts may take up locally, the oldest are removed first
+		MaxSizeMB int
+	}
+	// Cgroup places the fuzzing processes of each run in their own cgroup v2, so the limits apply to the whole
+	// process tree. In fork mode `-rss_limit_mb` only limits each child process on its own.
+	Cgroup struct {
+		// Enabled creates a cgroup for the campaign (or target) under Parent for every fuzzing run
+		Enabled bool
+		// Parent is the cgroup v2 directory the cgroups are created in, it is created if missing. FuzzerMan needs to be
+		// able to write to it, and the controllers for the configured limits need to be enabled in the
+		// cgroup.subtree_control of the directory above it. Defaults to /sys/fs/cgroup/fuzzerman
+		Parent string
+		// MemoryMaxMB is the cgroup's memory.max in megabytes, processes are OOM killed when it is reached
+		MemoryMaxMB int
+		// CPUs is the number of CPUs worth of time the cgroup may use, written to cpu.max (ex. 2.5)
+		CPUs float64
+		// PidsMax is the cgroup's pids.max, the number of processes and threads it may have
+		PidsMax int
+	}
+	// Sandbox runs the target in its own Linux namespaces while fuzzing and merging, using `unshare`. It needs user
+	// namespaces to be allowed, or FuzzerMan to run as root with CAP_SYS_ADMIN.
+	Sandbox struct {
+		// Enabled runs the target without network access and with the filesystem read-only, except for the corpus,
+		// artifact, engine and temp directories and a private /tmp
+		Enabled bool
+		// WritablePaths are extra paths the target may write to, they must exist
+		WritablePaths []string
+	}
+	// Minimize controls automatic minimization of new crashes
+	Minimize struct {
+		// Enabled will run the target with `-minimize_crash=1` on each new crash before it is reported. The minimized
+		// input is uploaded next to the original as `<artifact>.minimized` and sent as the `minimized` report field.
+		Enabled bool
+		// Runs is the `-runs` value used for minimization (default: 10000)
+		Runs int
+		// MaxTotalTime is the `-max_total_time` value in seconds used for minimization (default: 300)
+		MaxTotalTime int
+	}
+}
+
+// DefaultGracePeriod is used when ShutdownGracePeriod is not set
+const DefaultGracePeriod = 30 * time.Second
+
+// GracePeriod returns the configured ShutdownGracePeriod as a duration
+func (c *Config) GracePeriod() time.Duration {
+	if c.ShutdownGracePeriod <= 0 {
+		return DefaultGracePeriod
+	}
+	return time.Duration(c.ShutdownGracePeriod) * time.Second
+}
+
+// DefaultManifestMaxAge is used when CorpusManifest.MaxAgeHours is not set
+const DefaultManifestMaxAge = 24 * time.Hour
+
+// ManifestMaxAge returns the configured CorpusManifest.MaxAgeHours as a duration
+func (c *Config) ManifestMaxAge() time.Duration {
+	if c.CorpusManifest.MaxAgeHours <= 0 {
+		return DefaultManifestMaxAge
+	}
+	return time.Duration(c.CorpusManifest.MaxAgeHours) * time.Hour
+}
+
+// DefaultMaxShardSize is used when CorpusShards.MaxShardSizeMB is not set
+const DefaultMaxShardSize = 64 * 1024 * 1024
+
+// MaxShardSize returns the configured CorpusShards.MaxShardSizeMB in bytes
+func (c *Config) MaxShardSize() int64 {
+	if c.CorpusShards.MaxShardSizeMB <= 0 {
+		return DefaultMaxShardSize
+	}
+	return int64(c.CorpusShards.MaxShardSizeMB) * 1024 * 1024
+}
+
+// DefaultCgroupParent is used when Cgroup.Parent is not set
+const DefaultCgroupParent = "/sys/fs/cgroup/fuzzerman"
+
+// DefaultUpdateCheckInterval is used when Fuzzer.UpdateCheckInterval is not set
+const DefaultUpdateCheckInterval = 5 * time.Minute
+
+// UpdateCheckInterval returns the configured Fuzzer.UpdateCheckInterval as a duration, zero when checks are disabled
+func (c *Config) UpdateCheckInterval() time.Duration {
+	if c.Fuzzer.UpdateCheckInterval < 0 {
+		return 0
+	} else if c.Fuzzer.UpdateCheckInterval == 0 {
+		return DefaultUpdateCheckInterval
+	}
+	return time.Duration(c.Fuzzer.UpdateCheckInterval) * time.Second
 }
 
 func Load(fn string) (*Config, error) {
@@ -10,10 +10,21 @@ import (
 type DirectoryName string
 
 const (
-	CorpusDirectory   DirectoryName = "corpus"
-	TempDirectory                   = "temp"
-	LogDirectory                    = "logs"
-	ArtifactDirectory               = "artifacts"
+	CorpusDirectory     DirectoryName = "corpus"
+	TempDirectory                     = "temp"
+	LogDirectory                      = "logs"
+	ArtifactDirectory                 = "artifacts"
+	SignatureDirectory                = "signatures"
+	MinimizedDirectory                = "minimized"
+	EngineDirectory                   = "engine"
+	DictionaryDirectory               = "dicts"
+	TargetDirectory                   = "targets"
+	SeedDirectory                     = "seeds"
+	MetadataDirectory                 = "metadata"
+	BuildDirectory                    = "builds"
+	BundleDirectory                   = "bundles"
+	ShardDirectory                    = "shards"
+	ReproducedDirectory               = "reproduced"
 )
 
 type FileName int
@@ -22,6 +33,17 @@ const (
 	MergeLockFile FileName = iota
 	CloudFuzzerFile
 	LocalFuzzerFile
+	CloudDictionaryFile
+	LocalDictionaryFile
+	CloudBuildPointerFile
+	CloudBuildPinFile
+	LocalBuildFile
+	CloudCorpusManifest
+	LocalCorpusManifestState
+	LocalShardIndex
+	LocalTargetTurnFile
+	LocalDictionaryTurnFile
+	LocalReproduceStateFile
 )
 
 func (c *Config) WorkPath(name DirectoryName) string {
@@ -36,6 +58,16 @@ func (c *Config) CloudPath(name DirectoryName) string {
 	return path.Join(c.CloudStorage.Prefix, string(name))
 }
 
+// ForTarget returns a copy of the configuration for one of the campaign's targets. The cloud prefix and work directory
+// are moved under `targets/<name>/` so each target has its own binary, corpus, artifacts and logs.
+func (c *Config) ForTarget(name string) *Config {
+	target := *c
+	target.Target = name
+	target.CloudStorage.Prefix = path.Join(c.CloudPath(TargetDirectory), name)
+	target.WorkDirectory = filepath.Join(c.WorkDirectory, string(TargetDirectory), name)
+	return &target
+}
+
 func (c *Config) FilePath(name FileName) string {
 	switch name {
 	case MergeLockFile:
@@ -44,6 +76,29 @@ func (c *Config) FilePath(name FileName) string {
 		return path.Join(c.CloudStorage.Prefix, "fuzzer")
 	case LocalFuzzerFile:
 		return filepath.Join(c.WorkDirectory, "fuzzer")
+	case CloudDictionaryFile:
+		return path.Join(c.CloudStorage.Prefix, "dict")
+	case LocalDictionaryFile:
+		return filepath.Join(c.WorkDirectory, "dict")
+	case CloudBuildPointerFile:
+		return path.Join(c.CloudStorage.Prefix, "build")
+	case CloudBuildPinFile:
+		return path.Join(c.CloudStorage.Prefix, "build.pin")
+	case LocalBuildFile:
+		return filepath.Join(c.WorkDirectory, "build")
+	case CloudCorpusManifest:
+		// Kept out of the corpus prefix, listing `corpus` would otherwise include anything named `corpus*`
+		return path.Join(c.CloudStorage.Prefix, "manifests", "corpus")
+	case LocalCorpusManifestState:
+		return filepath.Join(c.WorkDirectory, "corpus.manifest")
+	case LocalShardIndex:
+		return filepath.Join(c.WorkDirectory, "corpus.shards")
+	case LocalTargetTurnFile:
+		return filepath.Join(c.WorkDirectory, "targets.turn")
+	case LocalDictionaryTurnFile:
+		return filepath.Join(c.WorkDirectory, "dict.turn")
+	case LocalReproduceStateFile:
+		return filepath.Join(c.WorkDirectory, "reproduce.state")
 	default:
 		panic(fmt.Sprintf("Unexpected config.FilePath argument (%v)", name))
 	}
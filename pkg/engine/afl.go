@@ -0,0 +1,205 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// AFLPlusPlus runs afl-fuzz with a main instance and ForkCount-1 secondary instances. Arguments are passed to
+// afl-fuzz, anything after a `--` entry is passed to the target instead (ex. `@@` to pass the input as a file).
+type AFLPlusPlus struct{}
+
+// [*] Fuzzing test case #12 (345 total, 2 crashes saved, state: in progress, ...
+var aflStatusRegex = regexp.MustCompile(`Fuzzing test case #\d+ \((\d+) total, (\d+) crashes saved`)
+
+func (e *AFLPlusPlus) Name() string {
+	return "aflplusplus"
+}
+
+// outputDir is afl-fuzz's `-o` directory, each instance gets its own subdirectory within it
+func (e *AFLPlusPlus) outputDir(opts Options) string {
+	return filepath.Join(opts.WorkDir, "afl")
+}
+
+func (e *AFLPlusPlus) environment(opts Options) []string {
+	env := opts.Environment
+	if env == nil {
+		// An empty environment means the host's is inherited, which needs to be kept when adding to it
+		env = os.Environ()
+	}
+	return append(env,
+		"AFL_NO_UI=1",
+		"AFL_SKIP_CPUFREQ=1",
+		"AFL_I_DONT_CARE_ABOUT_MISSING_CRASHES=1",
+	)
+}
+
+func (e *AFLPlusPlus) FuzzCommands(opts Options, log io.Writer) ([]*exec.Cmd, error) {
+	engineArgs, targetArgs := splitArguments(opts.Arguments)
+
+	// Each session starts fresh from the local corpus, anything worth keeping was collected after the last session
+	output := e.outputDir(opts)
+	if err := os.RemoveAll(output); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(output, 0770); err != nil {
+		return nil, err
+	}
+
+	// afl-fuzz refuses to start without at least one input
+	input := opts.CorpusDir
+	if isEmptyDir(input) {
+		input = filepath.Join(opts.WorkDir, "afl-seed")
+		if err := os.MkdirAll(input, 0770); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(filepath.Join(input, "seed"), []byte("0"), 0660); err != nil {
+			return nil, err
+		}
+	}
+
+	jobs := opts.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	var cmds []*exec.Cmd
+	for i := 0; i < jobs; i++ {
+		var args []string
+		args = append(args, "-i", input, "-o", output)
+		if i == 0 {
+			args = append(args, "-M", "main")
+		} else {
+			args = append(args, "-S", fmt.Sprintf("secondary%d", i))
+		}
+		if opts.MaxTotalTime > 0 {
+			args = append(args, "-V", strconv.Itoa(opts.MaxTotalTime))
+		}
+		if opts.Dictionary != "" {
+			args = append(args, "-x", opts.Dictionary)
+		}
+		args = append(args, engineArgs...)
+		args = append(args, "--", opts.Binary)
+		args = append(args, targetArgs...)
+
+		cmd := exec.Command("afl-fuzz", args...)
+		cmd.Env = e.environment(opts)
+		// Only the main instance is logged, the secondaries would just repeat the same status lines
+		if i == 0 {
+			cmd.Stdout = log
+			cmd.Stderr = log
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds, nil
+}
+
+func (e *AFLPlusPlus) MergeCommand(ctx context.Context, opts Options, outputDir string, corpusDirs ...string) (*exec.Cmd, error) {
+	if len(corpusDirs) != 1 {
+		return nil, errors.New("afl-cmin only supports merging a single corpus directory")
+	}
+	_, targetArgs := splitArguments(opts.Arguments)
+
+	var args []string
+	args = append(args, "-i", corpusDirs[0], "-o", outputDir)
+	args = append(args, "--", opts.Binary)
+	args = append(args, targetArgs...)
+
+	cmd := exec.CommandContext(ctx, "afl-cmin", args...)
+	cmd.Env = e.environment(opts)
+	return cmd, nil
+}
+
+func (e *AFLPlusPlus) ReproduceCommand(ctx context.Context, opts Options, input string) (*exec.Cmd, error) {
+	_, targetArgs := splitArguments(opts.Arguments)
+	args, fileInput := targetArguments(targetArgs, "@@", input)
+
+	cmd := exec.CommandContext(ctx, opts.Binary, args...)
+	cmd.Env = opts.Environment
+	if !fileInput {
+		content, err := os.ReadFile(input)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Stdin = bytes.NewReader(content)
+	}
+	return cmd, nil
+}
+
+func (e *AFLPlusPlus) MinimizeCommand(ctx context.Context, opts Options, input, outputPath string) (*exec.Cmd, error) {
+	_, targetArgs := splitArguments(opts.Arguments)
+
+	var args []string
+	args = append(args, "-i", input, "-o", outputPath)
+	args = append(args, "--", opts.Binary)
+	args = append(args, targetArgs...)
+
+	cmd := exec.CommandContext(ctx, "afl-tmin", args...)
+	cmd.Env = e.environment(opts)
+	return cmd, nil
+}
+
+// Collect syncs every instance's queue into the corpus directory, and its crashes and hangs into the artifact
+// directory using libFuzzer's `crash-<sha1>` and `timeout-<sha1>` naming.
+func (e *AFLPlusPlus) Collect(opts Options, log []byte) ([]Crash, error) {
+	var crashes []Crash
+	output := e.outputDir(opts)
+	instances, err := os.ReadDir(output)
+	if err != nil {
+		return crashes, err
+	}
+
+	for _, instance := range instances {
+		if !instance.IsDir() {
+			continue
+		}
+		dir := filepath.Join(output, instance.Name())
+
+		if _, err := importInputs(filepath.Join(dir, "queue"), opts.CorpusDir, ""); err != nil {
+			return crashes, err
+		}
+		if _, err := importInputs(filepath.Join(dir, "hangs"), opts.ArtifactDir, "timeout-"); err != nil {
+			return crashes, err
+		}
+		names, err := importInputs(filepath.Join(dir, "crashes"), opts.ArtifactDir, "crash-")
+		if err != nil {
+			return crashes, err
+		}
+
+		// afl-fuzz doesn't log the target's output, the crash is run again while it is reported
+		for _, name := range names {
+			crashes = append(crashes, Crash{Artifact: name})
+		}
+	}
+	return crashes, nil
+}
+
+// ExitStatus for afl-fuzz is only non-zero when it failed to run, crashes don't stop the session
+func (e *AFLPlusPlus) ExitStatus(code int) ExitStatus {
+	if code == 0 {
+		return ExitOK
+	}
+	return ExitError
+}
+
+// ParseStats parses the status line afl-fuzz prints when it is not attached to a terminal:
+//
+//	[*] Fuzzing test case #12 (345 total, 2 crashes saved, state: in progress, mode=explore, ...
+func (e *AFLPlusPlus) ParseStats(line string) (StatsSample, bool) {
+	var out StatsSample
+	m := aflStatusRegex.FindStringSubmatch(line)
+	if m == nil {
+		return out, false
+	}
+	out.Corpus, _ = strconv.ParseInt(m[1], 10, 64)
+	out.Crashes, _ = strconv.ParseInt(m[2], 10, 64)
+	return out, true
+}
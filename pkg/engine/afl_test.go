@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAFLPlusPlusCollect(t *testing.T) {
+	root := t.TempDir()
+	opts := Options{
+		CorpusDir:   filepath.Join(root, "corpus"),
+		ArtifactDir: filepath.Join(root, "artifacts"),
+		WorkDir:     filepath.Join(root, "engine"),
+	}
+	files := map[string]string{
+		"afl/main/queue/id:000000,time:0,execs:0,orig:seed":     "seed",
+		"afl/main/queue/.state/auto_extras/x":                   "ignored",
+		"afl/main/crashes/README.txt":                           "ignored",
+		"afl/main/crashes/id:000000,sig:06,src:000000,op:havoc": "crashing input",
+		"afl/secondary1/queue/id:000001,src:000000,op:havoc":    "new coverage",
+		"afl/secondary1/hangs/id:000000,src:000000,op:havoc":    "slow input",
+	}
+	for fn, content := range files {
+		fn = filepath.Join(opts.WorkDir, fn)
+		_ = os.MkdirAll(filepath.Dir(fn), 0770)
+		if err := os.WriteFile(fn, []byte(content), 0660); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = os.MkdirAll(opts.CorpusDir, 0770)
+	_ = os.MkdirAll(opts.ArtifactDir, 0770)
+
+	crashes, err := (&AFLPlusPlus{}).Collect(opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(crashes) != 1 || !strings.HasPrefix(crashes[0].Artifact, "crash-") || len(crashes[0].Excerpt) != 0 {
+		t.Errorf("unexpected crashes: %+v", crashes)
+	}
+
+	corpus, _ := os.ReadDir(opts.CorpusDir)
+	if len(corpus) != 2 {
+		t.Errorf("expected 2 corpus entries, got %d", len(corpus))
+	}
+	artifacts, _ := os.ReadDir(opts.ArtifactDir)
+	if len(artifacts) != 2 {
+		t.Errorf("expected a crash and a timeout artifact, got %d", len(artifacts))
+	}
+
+	// Collecting the same output again shouldn't report the crash twice
+	if crashes, _ = (&AFLPlusPlus{}).Collect(opts, nil); len(crashes) != 0 {
+		t.Errorf("expected no new crashes, got %d", len(crashes))
+	}
+}
+
+func TestAFLPlusPlusParseStats(t *testing.T) {
+	line := "[*] Fuzzing test case #12 (345 total, 2 crashes saved, state: in progress, mode=explore, perf_score=100)..."
+	sample, ok := (&AFLPlusPlus{}).ParseStats(line)
+	if !ok || sample.Corpus != 345 || sample.Crashes != 2 {
+		t.Errorf("unexpected sample %+v (%v)", sample, ok)
+	}
+	if _, ok = (&AFLPlusPlus{}).ParseStats("[+] All set and ready to roll!"); ok {
+		t.Errorf("expected no sample")
+	}
+}
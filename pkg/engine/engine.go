@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+)
+
+// ErrUnsupported is returned when an engine has no equivalent for the requested operation
+var ErrUnsupported = errors.New("not supported by this engine")
+
+// Options describes the target and directories an engine's commands operate on. They are built by the tasks from
+// the campaign configuration.
+type Options struct {
+	// Binary is the path to the target binary
+	Binary string
+	// CorpusDir is the local corpus directory, it is mirrored with the cloud corpus
+	CorpusDir string
+	// ArtifactDir is where crashing inputs need to end up to be uploaded and reported
+	ArtifactDir string
+	// WorkDir is a scratch directory for the engine's own output
+	WorkDir string
+	// Jobs is the number of parallel fuzzing processes (ForkCount)
+	Jobs int
+	// MaxTotalTime is the number of seconds the session (or minimization) should run for
+	MaxTotalTime int
+	// Runs limits the number of executions where the engine supports it, it is only used for minimization
+	Runs int
+	// FuzzFunction is the name of the fuzz function to run, it is only used for Go native fuzzing
+	FuzzFunction string
+	// Dictionary is the path to a dictionary file to fuzz with, it is empty when there is none
+	Dictionary string
+	// Arguments are the user's extra arguments, passed through to the engine as is
+	Arguments []string
+	// Environment is the full environment the target should be run with
+	Environment []string
+}
+
+// ExitStatus is the engine independent meaning of a fuzzing session's exit code
+type ExitStatus int
+
+const (
+	// ExitOK means the session ran to completion
+	ExitOK ExitStatus = iota
+	// ExitCrash means the session stopped because the target crashed
+	ExitCrash
+	// ExitResourceLimit means the session stopped on an OOM or timeout
+	ExitResourceLimit
+	// ExitError is any other unexpected exit code
+	ExitError
+)
+
+// StatsSample is a single progress update reported by the engine
+type StatsSample struct {
+	// Elapsed is the number of seconds since the fuzzer was started when this line was seen
+	Elapsed    int   `json:"elapsed"`
+	Runs       int64 `json:"runs"`
+	Coverage   int64 `json:"cov"`
+	Features   int64 `json:"ft"`
+	Corpus     int64 `json:"corp"`
+	ExecPerSec int64 `json:"exec_per_sec"`
+	OOMs       int64 `json:"ooms"`
+	Timeouts   int64 `json:"timeouts"`
+	Crashes    int64 `json:"crashes"`
+}
+
+// Crash is a single crash found during a fuzzing session
+type Crash struct {
+	// Artifact is the filename of the crashing input within the artifact directory
+	Artifact string
+	// Excerpt is the output belonging to this crash, ideally starting with the sanitizer report. It is empty for
+	// engines that don't log the target's output, the crash has to be run again with ReproduceCommand to get it.
+	Excerpt []byte
+}
+
+// Engine is implemented by each fuzzing engine FuzzerMan can drive. Commands that are given a context are killed
+// when it is cancelled, the fuzzing commands are not so that the caller can interrupt them and let them exit cleanly.
+type Engine interface {
+	// Name is the name used to select the engine in the configuration
+	Name() string
+	// FuzzCommands builds the processes making up a single fuzzing session. The engine's output should be written
+	// to log, anything it needs to parse stats or crashes from.
+	FuzzCommands(opts Options, log io.Writer) ([]*exec.Cmd, error)
+	// MergeCommand builds a command that minimizes the inputs in corpusDirs into outputDir
+	MergeCommand(ctx context.Context, opts Options, outputDir string, corpusDirs ...string) (*exec.Cmd, error)
+	// ReproduceCommand builds a command that runs the target once against input
+	ReproduceCommand(ctx context.Context, opts Options, input string) (*exec.Cmd, error)
+	// MinimizeCommand builds a command that minimizes a crashing input, writing the result to outputPath
+	MinimizeCommand(ctx context.Context, opts Options, input, outputPath string) (*exec.Cmd, error)
+	// Collect is called once a session has exited with the session's log. It moves any corpus entries or artifacts
+	// the engine wrote elsewhere into CorpusDir and ArtifactDir and returns the crashes found during the session.
+	Collect(opts Options, log []byte) ([]Crash, error)
+	// ExitStatus interprets the exit code of a session's primary process
+	ExitStatus(code int) ExitStatus
+	// ParseStats parses a progress line from the session's log
+	ParseStats(line string) (StatsSample, bool)
+}
+
+// DefaultEngine is used when the configuration does not name an engine
+const DefaultEngine = "libfuzzer"
+
+// Get returns the engine with the given name, an empty name selects the DefaultEngine
+func Get(name string) (Engine, error) {
+	switch name {
+	case "", DefaultEngine:
+		return &LibFuzzer{}, nil
+	case "aflplusplus":
+		return &AFLPlusPlus{}, nil
+	case "honggfuzz":
+		return &Honggfuzz{}, nil
+	case "go":
+		return &GoNative{}, nil
+	default:
+		return nil, fmt.Errorf("unknown fuzzing engine '%s'", name)
+	}
+}
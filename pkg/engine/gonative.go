@@ -0,0 +1,265 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// GoNative runs Go's native fuzzing using a test binary built with `go test -c`. Arguments are passed to the test
+// binary as is (ex. `-test.fuzzminimizetime=30s`). Go has no dictionary support so the Dictionary is ignored.
+//
+// The shared corpus holds raw inputs like the other engines. Go corpus files with a single `[]byte` value are
+// translated to and from raw inputs, any other file (multiple or non-[]byte values) is stored as is.
+type GoNative struct{}
+
+const goCorpusHeader = "go test fuzz v1\n"
+
+// fuzz: elapsed: 3s, execs: 1234 (411/sec), new interesting: 5 (total: 10)
+var goStatusRegex = regexp.MustCompile(`^fuzz: elapsed: \d+s, execs: (\d+) \((\d+)/sec\), new interesting: \d+ \(total: (\d+)\)`)
+
+func (e *GoNative) Name() string {
+	return "go"
+}
+
+// workspace is the test binary's working directory, failing inputs are written to `testdata/fuzz/<func>` within it
+// and the generated corpus to `cache/<func>`
+func (e *GoNative) workspace(opts Options) string {
+	return filepath.Join(opts.WorkDir, "go")
+}
+
+func (e *GoNative) function(opts Options) (string, error) {
+	if opts.FuzzFunction == "" {
+		return "", errors.New("the go engine requires Fuzzer.FuzzFunction to be set")
+	}
+	return opts.FuzzFunction, nil
+}
+
+func (e *GoNative) FuzzCommands(opts Options, log io.Writer) ([]*exec.Cmd, error) {
+	function, err := e.function(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each session starts with an empty workspace, anything worth keeping was collected after the last session
+	workspace := e.workspace(opts)
+	if err := os.RemoveAll(workspace); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(workspace, "testdata", "fuzz", function), 0770); err != nil {
+		return nil, err
+	}
+	// The shared corpus is given to Go as its generated corpus rather than the seed corpus, seeds are always run as
+	// tests before fuzzing begins and any failure among them stops the session
+	cache := filepath.Join(workspace, "cache", function)
+	if err := os.MkdirAll(cache, 0770); err != nil {
+		return nil, err
+	}
+	if err := exportGoCorpus(opts.CorpusDir, cache); err != nil {
+		return nil, err
+	}
+
+	jobs := opts.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	var args []string
+	args = append(args, "-test.run=^$")
+	args = append(args, fmt.Sprintf("-test.fuzz=^%s$", regexp.QuoteMeta(function)))
+	args = append(args, fmt.Sprintf("-test.fuzzcachedir=%s", filepath.Join(workspace, "cache")))
+	args = append(args, fmt.Sprintf("-test.parallel=%d", jobs))
+	if opts.MaxTotalTime > 0 {
+		args = append(args, fmt.Sprintf("-test.fuzztime=%ds", opts.MaxTotalTime))
+	}
+	args = append(args, opts.Arguments...)
+
+	cmd := exec.Command(opts.Binary, args...)
+	cmd.Env = opts.Environment
+	cmd.Dir = workspace
+	// Progress and failures are printed to stdout
+	cmd.Stdout = log
+	cmd.Stderr = log
+	return []*exec.Cmd{cmd}, nil
+}
+
+// MergeCommand is unsupported, Go has no corpus minimization
+func (e *GoNative) MergeCommand(ctx context.Context, opts Options, outputDir string, corpusDirs ...string) (*exec.Cmd, error) {
+	return nil, ErrUnsupported
+}
+
+// ReproduceCommand writes the input as a Go corpus file into a separate workspace's `testdata/fuzz` and runs it as
+// a sub-test of the fuzz function.
+func (e *GoNative) ReproduceCommand(ctx context.Context, opts Options, input string) (*exec.Cmd, error) {
+	function, err := e.function(opts)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(input)
+	if err != nil {
+		return nil, err
+	}
+
+	workspace := filepath.Join(opts.WorkDir, "go-reproduce")
+	testdata := filepath.Join(workspace, "testdata", "fuzz", function)
+	if err = os.MkdirAll(testdata, 0770); err != nil {
+		return nil, err
+	}
+	name := filepath.Base(input)
+	if err = os.WriteFile(filepath.Join(testdata, name), marshalGoCorpus(content), 0660); err != nil {
+		return nil, err
+	}
+
+	var args []string
+	args = append(args, fmt.Sprintf("-test.run=^%s$/^%s$", regexp.QuoteMeta(function), regexp.QuoteMeta(name)))
+	args = append(args, opts.Arguments...)
+
+	cmd := exec.CommandContext(ctx, opts.Binary, args...)
+	cmd.Env = opts.Environment
+	cmd.Dir = workspace
+	return cmd, nil
+}
+
+// MinimizeCommand is unsupported, Go already minimizes failing inputs before writing them
+func (e *GoNative) MinimizeCommand(ctx context.Context, opts Options, input, outputPath string) (*exec.Cmd, error) {
+	return nil, ErrUnsupported
+}
+
+// Collect translates the generated corpus back into the corpus directory, and the failing inputs from
+// `testdata/fuzz` into the artifact directory as `crash-<sha1>`.
+func (e *GoNative) Collect(opts Options, log []byte) ([]Crash, error) {
+	var crashes []Crash
+	function, err := e.function(opts)
+	if err != nil {
+		return crashes, err
+	}
+	workspace := e.workspace(opts)
+
+	if _, err := importConvertedInputs(filepath.Join(workspace, "cache", function), opts.CorpusDir, "", unmarshalGoCorpus); err != nil {
+		return crashes, err
+	}
+	names, err := importConvertedInputs(filepath.Join(workspace, "testdata", "fuzz", function), opts.ArtifactDir, "crash-", unmarshalGoCorpus)
+	if err != nil {
+		return crashes, err
+	}
+
+	// Go stops at the first failure so everything after the failing test's output belongs to it
+	excerpt := goFailureExcerpt(log)
+	for _, name := range names {
+		crashes = append(crashes, Crash{Artifact: name, Excerpt: excerpt})
+	}
+	return crashes, nil
+}
+
+// ExitStatus for a test binary is 1 when the fuzz test failed, anything else means it could not run
+func (e *GoNative) ExitStatus(code int) ExitStatus {
+	switch code {
+	case 0:
+		return ExitOK
+	case 1:
+		return ExitCrash
+	default:
+		return ExitError
+	}
+}
+
+// ParseStats parses the progress lines Go prints while fuzzing:
+//
+//	fuzz: elapsed: 3s, execs: 1234 (411/sec), new interesting: 5 (total: 10)
+func (e *GoNative) ParseStats(line string) (StatsSample, bool) {
+	var out StatsSample
+	m := goStatusRegex.FindStringSubmatch(line)
+	if m == nil {
+		return out, false
+	}
+	out.Runs, _ = strconv.ParseInt(m[1], 10, 64)
+	out.ExecPerSec, _ = strconv.ParseInt(m[2], 10, 64)
+	out.Corpus, _ = strconv.ParseInt(m[3], 10, 64)
+	return out, true
+}
+
+// exportGoCorpus writes every input in srcDir into dstDir as a Go corpus file
+func exportGoCorpus(srcDir, dstDir string) error {
+	files, err := os.ReadDir(srcDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(srcDir, f.Name()))
+		if err != nil {
+			return err
+		}
+		if err = os.WriteFile(filepath.Join(dstDir, f.Name()), marshalGoCorpus(content), 0660); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// marshalGoCorpus encodes a raw input as a Go corpus file with a single []byte value. Inputs that already are a Go
+// corpus file are returned as is.
+func marshalGoCorpus(input []byte) []byte {
+	if bytes.HasPrefix(input, []byte(goCorpusHeader)) {
+		return input
+	}
+	return []byte(fmt.Sprintf("%s[]byte(%s)\n", goCorpusHeader, strconv.Quote(string(input))))
+}
+
+// unmarshalGoCorpus decodes a Go corpus file with a single []byte value into the raw input. Anything else is
+// returned unchanged so that no information is lost in the shared corpus.
+func unmarshalGoCorpus(content []byte) []byte {
+	if !bytes.HasPrefix(content, []byte(goCorpusHeader)) {
+		return content
+	}
+
+	var values []string
+	for _, line := range strings.Split(string(content[len(goCorpusHeader):]), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+		values = append(values, line)
+	}
+	if len(values) != 1 || !strings.HasPrefix(values[0], "[]byte(") || !strings.HasSuffix(values[0], ")") {
+		return content
+	}
+
+	raw, err := strconv.Unquote(strings.TrimSuffix(strings.TrimPrefix(values[0], "[]byte("), ")"))
+	if err != nil {
+		return content
+	}
+	return []byte(raw)
+}
+
+// goFailureExcerpt returns the log from the first failing test onwards, or the entire log if there is none
+func goFailureExcerpt(log []byte) []byte {
+	var out []string
+	scanner := bufio.NewScanner(bytes.NewReader(log))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(out) == 0 && !strings.HasPrefix(line, "--- FAIL:") {
+			continue
+		}
+		out = append(out, line)
+	}
+	if len(out) == 0 {
+		return log
+	}
+	return []byte(strings.Join(out, "\n") + "\n")
+}
@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGoCorpusTranslation(t *testing.T) {
+	for _, input := range []string{"", "hello", "\x00\xff\"quoted\"\n"} {
+		encoded := marshalGoCorpus([]byte(input))
+		if !strings.HasPrefix(string(encoded), goCorpusHeader+"[]byte(") {
+			t.Errorf("unexpected encoding %q", encoded)
+		}
+		if decoded := unmarshalGoCorpus(encoded); string(decoded) != input {
+			t.Errorf("round trip of %q gave %q", input, decoded)
+		}
+	}
+
+	// Files that can't be represented as a raw input are kept as is in both directions
+	multi := []byte(goCorpusHeader + "string(\"a\")\nint(1)\n")
+	if out := unmarshalGoCorpus(multi); string(out) != string(multi) {
+		t.Errorf("expected multi-value file to be unchanged, got %q", out)
+	}
+	if out := marshalGoCorpus(multi); string(out) != string(multi) {
+		t.Errorf("expected go corpus file to be unchanged, got %q", out)
+	}
+}
+
+func TestGoNativeCollect(t *testing.T) {
+	root := t.TempDir()
+	opts := Options{
+		CorpusDir:    filepath.Join(root, "corpus"),
+		ArtifactDir:  filepath.Join(root, "artifacts"),
+		WorkDir:      filepath.Join(root, "engine"),
+		FuzzFunction: "FuzzParse",
+	}
+	files := map[string]string{
+		"go/cache/FuzzParse/8a3b10c4f5d22e1c":         goCorpusHeader + "[]byte(\"seed\")\n",
+		"go/testdata/fuzz/FuzzParse/771e938e4458e983": goCorpusHeader + "[]byte(\"\\x00crash\")\n",
+	}
+	for fn, content := range files {
+		fn = filepath.Join(opts.WorkDir, fn)
+		_ = os.MkdirAll(filepath.Dir(fn), 0770)
+		if err := os.WriteFile(fn, []byte(content), 0660); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = os.MkdirAll(opts.CorpusDir, 0770)
+	_ = os.MkdirAll(opts.ArtifactDir, 0770)
+
+	log := "fuzz: elapsed: 0s, execs: 10 (100/sec), new interesting: 0 (total: 1)\n" +
+		"--- FAIL: FuzzParse (0.01s)\n    --- FAIL: FuzzParse (0.00s)\n        panic: boom\n" +
+		"    Failing input written to testdata/fuzz/FuzzParse/771e938e4458e983\nFAIL\n"
+	crashes, err := (&GoNative{}).Collect(opts, []byte(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(crashes) != 1 || !strings.HasPrefix(string(crashes[0].Excerpt), "--- FAIL: FuzzParse") {
+		t.Fatalf("unexpected crashes: %+v", crashes)
+	}
+	content, _ := os.ReadFile(filepath.Join(opts.ArtifactDir, crashes[0].Artifact))
+	if string(content) != "\x00crash" {
+		t.Errorf("expected the raw failing input, got %q", content)
+	}
+	corpus, _ := os.ReadDir(opts.CorpusDir)
+	if len(corpus) != 1 {
+		t.Errorf("expected 1 corpus entry, got %d", len(corpus))
+	}
+}
+
+func TestGoNativeParseStats(t *testing.T) {
+	sample, ok := (&GoNative{}).ParseStats("fuzz: elapsed: 3s, execs: 1234 (411/sec), new interesting: 5 (total: 10)")
+	if !ok || sample.Runs != 1234 || sample.ExecPerSec != 411 || sample.Corpus != 10 {
+		t.Errorf("unexpected sample %+v (%v)", sample, ok)
+	}
+	if _, ok = (&GoNative{}).ParseStats("fuzz: elapsed: 0s, gathering baseline coverage: 0/1 completed"); ok {
+		t.Errorf("expected no sample")
+	}
+}
@@ -0,0 +1,194 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Honggfuzz runs honggfuzz with ForkCount threads. Arguments are passed to honggfuzz, anything after a `--` entry
+// is passed to the target instead (ex. `___FILE___` to pass the input as a file).
+type Honggfuzz struct{}
+
+// honggfuzz logs a line for every input that adds coverage, Cur holds the running totals of the
+// (instructions/branches/hardware edges/edges/pcs/cmps) feedback:
+//
+//	Sz:54 Tm:1,142us (i/b/h/e/p/c) New:0/0/0/2/0/17, Cur:0/0/0/128/6/1042
+var honggfuzzNewUnitRegex = regexp.MustCompile(`Sz:\d+ Tm:[\d,]+us \(i/b/h/e/p/c\) New:[\d/]+, Cur:(\d+)/(\d+)/(\d+)/(\d+)/(\d+)/(\d+)`)
+
+func (e *Honggfuzz) Name() string {
+	return "honggfuzz"
+}
+
+// workspace is honggfuzz's `--workspace` directory, new corpus and crashes are written to subdirectories of it
+func (e *Honggfuzz) workspace(opts Options) string {
+	return filepath.Join(opts.WorkDir, "honggfuzz")
+}
+
+func (e *Honggfuzz) FuzzCommands(opts Options, log io.Writer) ([]*exec.Cmd, error) {
+	engineArgs, targetArgs := splitArguments(opts.Arguments)
+
+	// Each session starts with an empty workspace, anything worth keeping was collected after the last session
+	workspace := e.workspace(opts)
+	if err := os.RemoveAll(workspace); err != nil {
+		return nil, err
+	}
+	for _, dir := range []string{"corpus", "crashes"} {
+		if err := os.MkdirAll(filepath.Join(workspace, dir), 0770); err != nil {
+			return nil, err
+		}
+	}
+
+	jobs := opts.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	var args []string
+	args = append(args, "--input", opts.CorpusDir)
+	args = append(args, "--output", filepath.Join(workspace, "corpus"))
+	args = append(args, "--workspace", workspace)
+	args = append(args, "--crashdir", filepath.Join(workspace, "crashes"))
+	args = append(args, "--threads", strconv.Itoa(jobs))
+	if opts.MaxTotalTime > 0 {
+		args = append(args, "--run_time", strconv.Itoa(opts.MaxTotalTime))
+	}
+	if opts.Dictionary != "" {
+		args = append(args, "--dict", opts.Dictionary)
+	}
+	args = append(args, engineArgs...)
+	args = append(args, "--", opts.Binary)
+	args = append(args, targetArgs...)
+
+	cmd := exec.Command("honggfuzz", args...)
+	cmd.Env = opts.Environment
+	cmd.Stdout = log
+	cmd.Stderr = log
+	return []*exec.Cmd{cmd}, nil
+}
+
+func (e *Honggfuzz) MergeCommand(ctx context.Context, opts Options, outputDir string, corpusDirs ...string) (*exec.Cmd, error) {
+	if len(corpusDirs) != 1 {
+		return nil, errors.New("honggfuzz only supports merging a single corpus directory")
+	}
+	engineArgs, targetArgs := splitArguments(opts.Arguments)
+
+	var args []string
+	args = append(args, "--input", corpusDirs[0])
+	args = append(args, "--output", outputDir)
+	args = append(args, "--minimize")
+	args = append(args, engineArgs...)
+	args = append(args, "--", opts.Binary)
+	args = append(args, targetArgs...)
+
+	cmd := exec.CommandContext(ctx, "honggfuzz", args...)
+	cmd.Env = opts.Environment
+	return cmd, nil
+}
+
+// ReproduceCommand runs the target with the input in place of `___FILE___`, or as the last argument which is how
+// binaries built with hfuzz-clang run a single input.
+func (e *Honggfuzz) ReproduceCommand(ctx context.Context, opts Options, input string) (*exec.Cmd, error) {
+	_, targetArgs := splitArguments(opts.Arguments)
+	args, found := targetArguments(targetArgs, "___FILE___", input)
+	if !found {
+		args = append(args, input)
+	}
+
+	cmd := exec.CommandContext(ctx, opts.Binary, args...)
+	cmd.Env = opts.Environment
+	return cmd, nil
+}
+
+// MinimizeCommand is unsupported, honggfuzz's `--minimize` only applies to a corpus
+func (e *Honggfuzz) MinimizeCommand(ctx context.Context, opts Options, input, outputPath string) (*exec.Cmd, error) {
+	return nil, ErrUnsupported
+}
+
+// Collect moves the new corpus honggfuzz wrote into the corpus directory, and the crashes into the artifact directory
+// renamed from honggfuzz's `SIGSEGV.PC.<pc>.STACK.<hash>...` naming to `crash-<sha1>`.
+func (e *Honggfuzz) Collect(opts Options, log []byte) ([]Crash, error) {
+	var crashes []Crash
+	workspace := e.workspace(opts)
+
+	if _, err := importInputs(filepath.Join(workspace, "corpus"), opts.CorpusDir, ""); err != nil {
+		return crashes, err
+	}
+	names, err := importInputs(filepath.Join(workspace, "crashes"), opts.ArtifactDir, "crash-")
+	if err != nil {
+		return crashes, err
+	}
+
+	// The target's output isn't logged, the crash is run again while it is reported
+	for _, name := range names {
+		crashes = append(crashes, Crash{Artifact: name})
+	}
+	return crashes, nil
+}
+
+// ExitStatus for honggfuzz is only non-zero when it failed to run, crashes don't stop the session
+func (e *Honggfuzz) ExitStatus(code int) ExitStatus {
+	if code == 0 {
+		return ExitOK
+	}
+	return ExitError
+}
+
+// ParseStats parses the line honggfuzz logs for every new input, and the summary it prints when it exits:
+//
+//	Summary iterations:1000 time:60 speed:16 crashes_count:1 timeout_count:0 new_units_added:12 ...
+//
+// Coverage is the number of edges (or hardware edges) covered. The summary only has the number of instrumented
+// edges, `guard_nb`, and the percentage of them covered.
+func (e *Honggfuzz) ParseStats(line string) (StatsSample, bool) {
+	var out StatsSample
+	if m := honggfuzzNewUnitRegex.FindStringSubmatch(line); m != nil {
+		var cur [6]int64
+		for i := range cur {
+			cur[i], _ = strconv.ParseInt(m[i+1], 10, 64)
+		}
+		out.Coverage = cur[2] + cur[3]
+		out.Features = cur[2] + cur[3] + cur[4] + cur[5]
+		return out, true
+	}
+
+	idx := strings.Index(line, "Summary iterations:")
+	if idx < 0 {
+		return out, false
+	}
+
+	var guards int64
+	var coveredPercent float64
+	for _, field := range strings.Fields(line[idx+len("Summary "):]) {
+		parts := strings.SplitN(field, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value := leadingInt(parts[1])
+		switch parts[0] {
+		case "iterations":
+			out.Runs = value
+		case "speed":
+			out.ExecPerSec = value
+		case "crashes_count":
+			out.Crashes = value
+		case "timeout_count":
+			out.Timeouts = value
+		case "new_units_added":
+			out.Corpus = value
+		case "guard_nb":
+			guards = value
+		case "branch_coverage_percent":
+			coveredPercent, _ = strconv.ParseFloat(parts[1], 64)
+		}
+	}
+	out.Coverage = int64(math.Round(float64(guards) * coveredPercent / 100))
+	return out, true
+}
@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHonggfuzzCollect(t *testing.T) {
+	root := t.TempDir()
+	opts := Options{
+		CorpusDir:   filepath.Join(root, "corpus"),
+		ArtifactDir: filepath.Join(root, "artifacts"),
+		WorkDir:     filepath.Join(root, "engine"),
+	}
+	files := map[string]string{
+		"honggfuzz/corpus/0123456789abcdef.00000004.honggfuzz.cov":                              "seed",
+		"honggfuzz/crashes/SIGSEGV.PC.555555555555.STACK.1a2b3c4d.CODE.1.ADDR.0.INSTR.mov.fuzz": "crashing input",
+		"honggfuzz/HONGGFUZZ.REPORT.TXT":                                                        "ignored",
+	}
+	for fn, content := range files {
+		fn = filepath.Join(opts.WorkDir, fn)
+		_ = os.MkdirAll(filepath.Dir(fn), 0770)
+		if err := os.WriteFile(fn, []byte(content), 0660); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = os.MkdirAll(opts.CorpusDir, 0770)
+	_ = os.MkdirAll(opts.ArtifactDir, 0770)
+
+	crashes, err := (&Honggfuzz{}).Collect(opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The crash is run again to get its output while it is reported
+	if len(crashes) != 1 || !strings.HasPrefix(crashes[0].Artifact, "crash-") || len(crashes[0].Excerpt) != 0 {
+		t.Errorf("unexpected crashes: %+v", crashes)
+	}
+	corpus, _ := os.ReadDir(opts.CorpusDir)
+	if len(corpus) != 1 {
+		t.Errorf("expected 1 corpus entry, got %d", len(corpus))
+	}
+}
+
+func TestHonggfuzzParseStats(t *testing.T) {
+	line := "Summary iterations:1000 time:60 speed:16 crashes_count:1 timeout_count:2 new_units_added:12 slowest_unit_ms:5 guard_nb:4096 branch_coverage_percent:1.250 peak_rss_mb:40"
+	sample, ok := (&Honggfuzz{}).ParseStats(line)
+	if !ok || sample.Runs != 1000 || sample.ExecPerSec != 16 || sample.Crashes != 1 || sample.Timeouts != 2 || sample.Corpus != 12 || sample.Coverage != 51 {
+		t.Errorf("unexpected sample %+v (%v)", sample, ok)
+	}
+	// Every new input logs the running coverage totals
+	sample, ok = (&Honggfuzz{}).ParseStats("Sz:54 Tm:1,142us (i/b/h/e/p/c) New:0/0/0/2/0/17, Cur:0/0/0/128/6/1042")
+	if !ok || sample.Coverage != 128 || sample.Features != 1176 {
+		t.Errorf("unexpected sample %+v (%v)", sample, ok)
+	}
+	if _, ok = (&Honggfuzz{}).ParseStats("Start time:'2023-01-01.00.00.00' bin:'./target'"); ok {
+		t.Errorf("expected no sample")
+	}
+}
+
+func TestHonggfuzzMergeCommand(t *testing.T) {
+	opts := Options{Binary: "target"}
+	if _, err := (&Honggfuzz{}).MergeCommand(context.Background(), opts, "out", "a", "b"); err == nil {
+		t.Errorf("expected an error for more than one corpus directory")
+	}
+	cmd, err := (&Honggfuzz{}).MergeCommand(context.Background(), opts, "out", "a")
+	if err != nil || !strings.Contains(strings.Join(cmd.Args, " "), "--input a --output out --minimize") {
+		t.Errorf("unexpected merge command %v (%v)", cmd, err)
+	}
+}
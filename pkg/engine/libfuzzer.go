@@ -0,0 +1,200 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LibFuzzer runs libFuzzer binaries in fork mode
+type LibFuzzer struct{}
+
+const (
+	artifactMarker = "Test unit written to "
+	// In fork mode the parent prints the log of the crashing job after this line
+	innerLogMarker = "INFO: log from the inner process:"
+)
+
+func (e *LibFuzzer) Name() string {
+	return "libfuzzer"
+}
+
+func (e *LibFuzzer) FuzzCommands(opts Options, log io.Writer) ([]*exec.Cmd, error) {
+	var args []string
+	args = append(args, fmt.Sprintf("-fork=%d", opts.Jobs))
+	args = append(args, fmt.Sprintf("-max_total_time=%d", opts.MaxTotalTime))
+	args = append(args, fmt.Sprintf("-artifact_prefix=%s/", opts.ArtifactDir))
+	if opts.Dictionary != "" {
+		args = append(args, fmt.Sprintf("-dict=%s", opts.Dictionary))
+	}
+	args = append(args, opts.Arguments...)
+	args = append(args, opts.CorpusDir)
+
+	cmd := exec.Command(opts.Binary, args...)
+	cmd.Env = opts.Environment
+	// libFuzzer prints its info to stderr only
+	cmd.Stderr = log
+	return []*exec.Cmd{cmd}, nil
+}
+
+func (e *LibFuzzer) MergeCommand(ctx context.Context, opts Options, outputDir string, corpusDirs ...string) (*exec.Cmd, error) {
+	var args []string
+	args = append(args, "-merge=1")
+	args = append(args, opts.Arguments...)
+	args = append(args, outputDir)
+	args = append(args, corpusDirs...)
+
+	cmd := exec.CommandContext(ctx, opts.Binary, args...)
+	cmd.Env = opts.Environment
+	return cmd, nil
+}
+
+func (e *LibFuzzer) ReproduceCommand(ctx context.Context, opts Options, input string) (*exec.Cmd, error) {
+	var args []string
+	args = append(args, opts.Arguments...)
+	args = append(args, input)
+
+	cmd := exec.CommandContext(ctx, opts.Binary, args...)
+	cmd.Env = opts.Environment
+	return cmd, nil
+}
+
+func (e *LibFuzzer) MinimizeCommand(ctx context.Context, opts Options, input, outputPath string) (*exec.Cmd, error) {
+	var args []string
+	args = append(args, "-minimize_crash=1")
+	args = append(args, fmt.Sprintf("-runs=%d", opts.Runs))
+	args = append(args, fmt.Sprintf("-max_total_time=%d", opts.MaxTotalTime))
+	args = append(args, fmt.Sprintf("-exact_artifact_path=%s", outputPath))
+	args = append(args, opts.Arguments...)
+	args = append(args, input)
+
+	cmd := exec.CommandContext(ctx, opts.Binary, args...)
+	cmd.Env = opts.Environment
+	// Any intermediate files libFuzzer writes end up next to the output rather than the current directory
+	cmd.Dir = filepath.Dir(outputPath)
+	return cmd, nil
+}
+
+// Collect finds the crashes in the log, libFuzzer already writes its artifacts and corpus to the right place
+func (e *LibFuzzer) Collect(opts Options, log []byte) ([]Crash, error) {
+	return FindCrashes(log), nil
+}
+
+func (e *LibFuzzer) ExitStatus(code int) ExitStatus {
+	switch code {
+	case 0:
+		return ExitOK
+	case 1:
+		return ExitCrash
+	case 77:
+		// This is usually an OOM/Timeout "crash"
+		return ExitResourceLimit
+	default:
+		return ExitError
+	}
+}
+
+// ParseStats parses the status lines libFuzzer prints such as:
+//
+//	#12345: cov: 1024 ft: 4096 corp: 300 exec/s: 2000 oom/timeout/crash: 0/1/2 time: 60s job: 5 dft_time: 0
+//	#12345	NEW    cov: 1024 ft: 4096 corp: 300/12Kb lim: 64 exec/s: 2000 rss: 40Mb L: 5/64 MS: 1 ChangeBit-
+//
+// the first being the fork mode format and the second being the regular single process format.
+func (e *LibFuzzer) ParseStats(line string) (StatsSample, bool) {
+	var out StatsSample
+	fields := strings.Fields(line)
+	if len(fields) < 2 || !strings.HasPrefix(fields[0], "#") {
+		return out, false
+	}
+
+	runs, err := strconv.ParseInt(strings.TrimSuffix(fields[0][1:], ":"), 10, 64)
+	if err != nil {
+		return out, false
+	}
+	out.Runs = runs
+
+	found := false
+	for i := 1; i < len(fields)-1; i++ {
+		key := strings.TrimSuffix(fields[i], ":")
+		value := fields[i+1]
+		switch key {
+		case "cov":
+			out.Coverage = leadingInt(value)
+		case "ft":
+			out.Features = leadingInt(value)
+		case "corp":
+			out.Corpus = leadingInt(value)
+		case "exec/s":
+			out.ExecPerSec = leadingInt(value)
+		case "oom/timeout/crash":
+			parts := strings.Split(value, "/")
+			if len(parts) == 3 {
+				out.OOMs = leadingInt(parts[0])
+				out.Timeouts = leadingInt(parts[1])
+				out.Crashes = leadingInt(parts[2])
+			}
+		default:
+			continue
+		}
+		found = true
+		i++
+	}
+	return out, found
+}
+
+// leadingInt parses the digits at the start of s, so values like `300/12Kb` or `60s` can be handled
+func leadingInt(s string) int64 {
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	v, _ := strconv.ParseInt(s[:end], 10, 64)
+	return v
+}
+
+// FindCrashes returns every artifact written according to the log in the order they were written. Each crash
+// gets the part of the log since the previous artifact, trimmed to start at the beginning of the crash output.
+func FindCrashes(content []byte) []Crash {
+	var out []Crash
+	var segment []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		segment = append(segment, line)
+		if !strings.Contains(line, artifactMarker) {
+			continue
+		}
+
+		artifact := strings.SplitN(line, artifactMarker, 2)[1]
+		out = append(out, Crash{
+			Artifact: filepath.Base(strings.TrimSpace(artifact)),
+			Excerpt:  []byte(strings.Join(crashExcerpt(segment), "\n") + "\n"),
+		})
+		segment = nil
+	}
+	return out
+}
+
+// crashExcerpt trims the status lines from the start of a log segment, so it begins with the crashing job's log
+// in fork mode, or otherwise with the sanitizer report.
+func crashExcerpt(segment []string) []string {
+	for i := len(segment) - 1; i >= 0; i-- {
+		if strings.HasPrefix(segment[i], innerLogMarker) {
+			return segment[i:]
+		}
+	}
+	for i, line := range segment {
+		if sanitizerHeaderRegex.MatchString(line) || ubsanHeaderRegex.MatchString(line) {
+			return segment[i:]
+		}
+	}
+	return segment
+}
@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+)
+
+const forkLog = `INFO: -fork=2: fuzzing in separate process(s)
+#1000: cov: 10 ft: 20 corp: 5 exec/s: 100 oom/timeout/crash: 0/0/0 time: 1s job: 1 dft_time: 0
+INFO: log from the inner process:
+INFO: Seed: 1
+==11==ERROR: AddressSanitizer: heap-use-after-free on address 0x1
+    #0 0x1 in first_bug /src/a.c:1:1
+artifact_prefix='/work/artifacts/'; Test unit written to /work/artifacts/crash-aaaa
+#2000: cov: 12 ft: 25 corp: 6 exec/s: 100 oom/timeout/crash: 0/0/1 time: 2s job: 2 dft_time: 0
+INFO: log from the inner process:
+INFO: Seed: 2
+==12==ERROR: AddressSanitizer: stack-buffer-overflow on address 0x2
+    #0 0x2 in second_bug /src/b.c:2:2
+artifact_prefix='/work/artifacts/'; Test unit written to /work/artifacts/crash-bbbb
+#3000: cov: 14 ft: 30 corp: 7 exec/s: 100 oom/timeout/crash: 0/0/2 time: 3s job: 3 dft_time: 0
+`
+
+func TestFindCrashes(t *testing.T) {
+	crashes := FindCrashes([]byte(forkLog))
+	if len(crashes) != 2 {
+		t.Fatalf("expected 2 crashes, got %d", len(crashes))
+	}
+
+	expected := []struct {
+		artifact  string
+		crashType string
+		frame     string
+	}{
+		{"crash-aaaa", "heap-use-after-free", "first_bug"},
+		{"crash-bbbb", "stack-buffer-overflow", "second_bug"},
+	}
+	for i, e := range expected {
+		crash := crashes[i]
+		if crash.Artifact != e.artifact {
+			t.Errorf("crash %d: artifact %q, expected %q", i, crash.Artifact, e.artifact)
+		}
+		if !strings.HasPrefix(string(crash.Excerpt), innerLogMarker) {
+			t.Errorf("crash %d: excerpt should start at the inner log: %q", i, crash.Excerpt)
+		}
+		if strings.Contains(string(crash.Excerpt), "oom/timeout/crash") {
+			t.Errorf("crash %d: excerpt should not contain status lines", i)
+		}
+		report := ParseSanitizerReport(crash.Excerpt)
+		if report == nil || report.CrashType != e.crashType || report.Frames[0] != e.frame {
+			t.Errorf("crash %d: unexpected report %+v", i, report)
+		}
+	}
+}
+
+func TestLibFuzzerParseStats(t *testing.T) {
+	tests := []struct {
+		line     string
+		ok       bool
+		expected StatsSample
+	}{
+		{
+			line:     "#123456: cov: 1024 ft: 4096 corp: 300 exec/s: 2000 oom/timeout/crash: 3/1/2 time: 60s job: 5 dft_time: 0",
+			ok:       true,
+			expected: StatsSample{Runs: 123456, Coverage: 1024, Features: 4096, Corpus: 300, ExecPerSec: 2000, OOMs: 3, Timeouts: 1, Crashes: 2},
+		},
+		{
+			line:     "#2048\tNEW    cov: 12 ft: 13 corp: 5/100b lim: 4 exec/s: 0 rss: 30Mb L: 3/3 MS: 1 ChangeBit-",
+			ok:       true,
+			expected: StatsSample{Runs: 2048, Coverage: 12, Features: 13, Corpus: 5},
+		},
+		{line: "INFO: Seed: 1234", ok: false},
+		{line: "#0: job 1 started", ok: false},
+		{line: "    #0 0x4f1a2b in LLVMFuzzerTestOneInput", ok: false},
+	}
+
+	for _, test := range tests {
+		sample, ok := (&LibFuzzer{}).ParseStats(test.line)
+		if ok != test.ok {
+			t.Errorf("ParseStats(%q) ok = %v, expected %v", test.line, ok, test.ok)
+			continue
+		}
+		if ok && sample != test.expected {
+			t.Errorf("ParseStats(%q) = %+v, expected %+v", test.line, sample, test.expected)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+// DefaultStackDepth is the number of frames used for a crash signature when none is configured
+const DefaultStackDepth = 5
+
+// SanitizerReport is the parsed form of the ASan/UBSan/MSan (or libFuzzer) error report found in a log
+type SanitizerReport struct {
+	// Sanitizer is the name of the tool that reported the crash (ex. AddressSanitizer)
+	Sanitizer string
+	// CrashType is the kind of bug reported (ex. heap-buffer-overflow)
+	CrashType string
+	// Frames contains the symbolized function names of the crashing stack, top-most first
+	Frames []string
+}
+
+var (
+	// ==1234==ERROR: AddressSanitizer: heap-buffer-overflow on address ...
+	// ==1234== ERROR: libFuzzer: deadly signal
+	// ==1234==WARNING: MemorySanitizer: use-of-uninitialized-value
+	sanitizerHeaderRegex = regexp.MustCompile(`^==\d+==\s*(?:ERROR|WARNING): (\w+): (.+?)(?: on | \(| after |$)`)
+	// file.c:10:5: runtime error: signed integer overflow: ...
+	ubsanHeaderRegex = regexp.MustCompile(`: runtime error: ([^:]+)`)
+	//     #0 0x4f1a2b in function_name /path/to/file.c:12:3
+	stackFrameRegex = regexp.MustCompile(`^\s*#(\d+)\s+0x[0-9a-fA-F]+\s+(?:in\s+(.*))?`)
+)
+
+// ignoredFramePrefixes are the sanitizer runtime and libFuzzer frames, they are the same for every crash so
+// they are skipped when building the signature
+var ignoredFramePrefixes = []string{
+	"__asan",
+	"__msan",
+	"__ubsan",
+	"__sanitizer",
+	"__interceptor_",
+	"__restore_rt",
+	"___interceptor_",
+	"fuzzer::",
+	"LLVMFuzzerTestOneInput",
+}
+
+// ParseSanitizerReport finds the first sanitizer report in the log and parses out the crash type and the crashing
+// stack trace. nil is returned if no report could be found.
+func ParseSanitizerReport(content []byte) *SanitizerReport {
+	var report *SanitizerReport
+	inStack := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if report == nil {
+			if m := sanitizerHeaderRegex.FindStringSubmatch(line); m != nil {
+				report = &SanitizerReport{Sanitizer: m[1], CrashType: m[2]}
+			} else if m := ubsanHeaderRegex.FindStringSubmatch(line); m != nil {
+				report = &SanitizerReport{Sanitizer: "UndefinedBehaviorSanitizer", CrashType: strings.TrimSpace(m[1])}
+			}
+			continue
+		}
+
+		m := stackFrameRegex.FindStringSubmatch(line)
+		if m == nil {
+			if inStack {
+				// Only the first stack trace is of interest, anything after is allocation/free stacks
+				break
+			}
+			continue
+		}
+		if m[1] == "0" && inStack {
+			break
+		}
+		inStack = true
+
+		if fn := frameFunction(m[2]); fn != "" {
+			report.Frames = append(report.Frames, fn)
+		}
+	}
+	return report
+}
+
+// frameFunction trims the location and arguments from a frame, leaving just the function name. An empty string is
+// returned for frames that should not be part of the signature.
+func frameFunction(frame string) string {
+	frame = strings.TrimSpace(frame)
+	if frame == "" {
+		return ""
+	}
+
+	// The location is always the final field, (ex. `/path/file.c:12:3` or `(binary+0x1234)`)
+	if idx := strings.LastIndexAny(frame, " \t"); idx > 0 {
+		frame = frame[:idx]
+	}
+	if idx := strings.Index(frame, "("); idx > 0 {
+		frame = frame[:idx]
+	}
+
+	for _, prefix := range ignoredFramePrefixes {
+		if strings.HasPrefix(frame, prefix) {
+			return ""
+		}
+	}
+	return frame
+}
+
+// TopFrames returns up to depth of the top-most frames, DefaultStackDepth is used if depth is not positive
+func (r *SanitizerReport) TopFrames(depth int) []string {
+	if depth <= 0 {
+		depth = DefaultStackDepth
+	}
+	if len(r.Frames) > depth {
+		return r.Frames[:depth]
+	}
+	return r.Frames
+}
+
+// Signature is a stable identifier for the crash built from the crash type and top-most depth frames
+func (r *SanitizerReport) Signature(depth int) string {
+	h := sha1.New()
+	h.Write([]byte(r.Sanitizer + "\n" + r.CrashType + "\n"))
+	h.Write([]byte(strings.Join(r.TopFrames(depth), "\n")))
+	return hex.EncodeToString(h.Sum(nil))
+}
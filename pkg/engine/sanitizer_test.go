@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+const asanLog = `INFO: Seed: 1234
+==4242==ERROR: AddressSanitizer: heap-buffer-overflow on address 0x602000000011 at pc 0x55d1 bp 0x7ffc sp 0x7ffc
+READ of size 1 at 0x602000000011 thread T0
+    #0 0x55d1 in __asan_memcpy (/fuzzer+0x4f1a2b)
+    #1 0x55d2 in parse_header(unsigned char const*, unsigned long) /src/parser.cc:42:7
+    #2 0x55d3 in parse /src/parser.cc:80:3
+    #3 0x55d4 in LLVMFuzzerTestOneInput /src/fuzz.cc:10:3
+    #4 0x55d5 in fuzzer::Fuzzer::ExecuteCallback(unsigned char const*, unsigned long) (/fuzzer+0x1234)
+    #5 0x7f00 (/lib/x86_64-linux-gnu/libc.so.6+0x29d8f)
+
+0x602000000011 is located 0 bytes to the right of 1-byte region
+allocated by thread T0 here:
+    #0 0x55e1 in malloc (/fuzzer+0x4f1a2b)
+    #1 0x55e2 in parse /src/parser.cc:70:3
+`
+
+const ubsanLog = `/src/math.c:10:5: runtime error: signed integer overflow: 2147483647 + 1 cannot be represented in type 'int'
+    #0 0x55d1 in add /src/math.c:10:5
+    #1 0x55d2 in LLVMFuzzerTestOneInput /src/fuzz.c:5:3
+SUMMARY: UndefinedBehaviorSanitizer: undefined-behavior /src/math.c:10:5
+`
+
+func TestParseSanitizerReport(t *testing.T) {
+	tests := []struct {
+		name     string
+		log      string
+		expected *SanitizerReport
+	}{
+		{
+			name:     "asan",
+			log:      asanLog,
+			expected: &SanitizerReport{Sanitizer: "AddressSanitizer", CrashType: "heap-buffer-overflow", Frames: []string{"parse_header", "parse"}},
+		},
+		{
+			name:     "ubsan",
+			log:      ubsanLog,
+			expected: &SanitizerReport{Sanitizer: "UndefinedBehaviorSanitizer", CrashType: "signed integer overflow", Frames: []string{"add"}},
+		},
+		{
+			name:     "libfuzzer",
+			log:      "==99== ERROR: libFuzzer: deadly signal\n    #0 0x1 in __sanitizer_print_stack_trace (/f+0x1)\n    #1 0x2 in crash_here /src/a.c:1:1\n",
+			expected: &SanitizerReport{Sanitizer: "libFuzzer", CrashType: "deadly signal", Frames: []string{"crash_here"}},
+		},
+		{
+			name:     "none",
+			log:      "INFO: Seed: 1234\n#2 INITED cov: 1 ft: 1 corp: 1/1b\n",
+			expected: nil,
+		},
+	}
+
+	for _, test := range tests {
+		report := ParseSanitizerReport([]byte(test.log))
+		if !reflect.DeepEqual(report, test.expected) {
+			t.Errorf("%s: got %+v, expected %+v", test.name, report, test.expected)
+		}
+	}
+}
+
+func TestSanitizerReportSignature(t *testing.T) {
+	a := SanitizerReport{Sanitizer: "AddressSanitizer", CrashType: "SEGV", Frames: []string{"a", "b", "c"}}
+	b := SanitizerReport{Sanitizer: "AddressSanitizer", CrashType: "SEGV", Frames: []string{"a", "b", "d"}}
+
+	if a.Signature(2) != b.Signature(2) {
+		t.Errorf("signatures should match when only the top 2 frames are considered")
+	}
+	if a.Signature(3) == b.Signature(3) {
+		t.Errorf("signatures should differ when the 3rd frame is considered")
+	}
+}
@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// splitArguments separates the engine's own arguments from the arguments for the target. Anything after a `--`
+// entry is passed to the target, (ex. `["-m", "none", "--", "@@"]`).
+func splitArguments(args []string) ([]string, []string) {
+	for i, arg := range args {
+		if arg == "--" {
+			return args[:i], args[i+1:]
+		}
+	}
+	return args, nil
+}
+
+// targetArguments replaces the engine's input placeholder (ex. `@@`) in the target arguments with the input path.
+// The returned bool is false if there was no placeholder, meaning the input needs to be given some other way.
+func targetArguments(args []string, placeholder, input string) ([]string, bool) {
+	var out []string
+	found := false
+	for _, arg := range args {
+		if strings.Contains(arg, placeholder) {
+			arg = strings.ReplaceAll(arg, placeholder, input)
+			found = true
+		}
+		out = append(out, arg)
+	}
+	return out, found
+}
+
+// importInputs copies every file in srcDir into dstDir named as prefix followed by the SHA1 of its content, the same
+// naming libFuzzer uses. Files already in dstDir are skipped, the names of newly written files are returned.
+func importInputs(srcDir, dstDir, prefix string) ([]string, error) {
+	return importConvertedInputs(srcDir, dstDir, prefix, nil)
+}
+
+// importConvertedInputs is importInputs for engines that store inputs in their own format, convert (if not nil)
+// turns each file's content into the raw input before it is named and written.
+func importConvertedInputs(srcDir, dstDir, prefix string, convert func([]byte) []byte) ([]string, error) {
+	var out []string
+	files, err := os.ReadDir(srcDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return out, nil
+		}
+		return out, err
+	}
+
+	for _, f := range files {
+		if f.IsDir() || strings.HasPrefix(f.Name(), ".") || f.Name() == "README.txt" {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(srcDir, f.Name()))
+		if err != nil {
+			return out, err
+		}
+		if convert != nil {
+			content = convert(content)
+		}
+
+		sum := sha1.Sum(content)
+		name := prefix + hex.EncodeToString(sum[:])
+		dst := filepath.Join(dstDir, name)
+		if _, err := os.Stat(dst); err == nil {
+			continue
+		}
+		if err = os.WriteFile(dst, content, 0660); err != nil {
+			return out, err
+		}
+		out = append(out, name)
+	}
+	return out, nil
+}
+
+// isEmptyDir returns true if the directory has no files, or does not exist
+func isEmptyDir(dirname string) bool {
+	files, err := os.ReadDir(dirname)
+	if err != nil {
+		return true
+	}
+	for _, f := range files {
+		if !f.IsDir() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,139 @@
+package tasks
+
+import (
+	"FuzzerMan/pkg/config"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gocloud.dev/gcerrors"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Target binaries can be versioned by uploading them as `builds/<sha256>` and writing the hash of the current build
+// into the `build` pointer object. A `build.pin` object takes precedence over the pointer, so a campaign can be
+// pinned to, or rolled back to, a previous build without the next upload moving it forward again.
+
+// remoteBuild returns the build the campaign should be running, an empty string means the campaign has no builds and
+// the `fuzzer` object is used as is
+func (task *SyncTargetBinaryTask) remoteBuild(cfg *config.Config) (string, error) {
+	for _, name := range []config.FileName{config.CloudBuildPinFile, config.CloudBuildPointerFile} {
+		if build, err := task.readBuild(cfg, name); err != nil || build != "" {
+			return build, err
+		}
+	}
+	return "", nil
+}
+
+// readBuild returns the build named by the pin or pointer object, an empty string means the object doesn't exist
+func (task *SyncTargetBinaryTask) readBuild(cfg *config.Config, name config.FileName) (string, error) {
+	content, err := task.cloud.ReadFile(cfg.FilePath(name), nil)
+	if err != nil && gcerrors.Code(err) == gcerrors.NotFound {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	build := strings.ToLower(strings.TrimSpace(string(content)))
+	if decoded, err := hex.DecodeString(build); err != nil || len(decoded) != 32 {
+		return "", fmt.Errorf("%s does not contain a SHA256 hash", path.Base(cfg.FilePath(name)))
+	}
+	return build, nil
+}
+
+// syncBuild makes the build the local `fuzzer`. Builds are cached locally so rolling back to a previously used build
+// doesn't need to download it again.
+func (task *SyncTargetBinaryTask) syncBuild(cfg *config.Config, build string) error {
+	localpath := cfg.FilePath(config.LocalFuzzerFile)
+	if _, err := os.Stat(localpath); err == nil && localBuild(cfg) == build {
+		return nil
+	}
+
+	cached := filepath.Join(cfg.WorkPath(config.BuildDirectory), build)
+	if _, err := os.Stat(cached); os.IsNotExist(err) {
+		log.Printf("[*] Fetching build %s", build[:12])
+		download := cached + ".download"
+		if err = task.cloud.DownloadSingle(path.Join(cfg.CloudPath(config.BuildDirectory), build), download); err != nil {
+			return errors.New("failed to fetch build: " + err.Error())
+		}
+		if hash, err := fileSHA256(download); err != nil || hash != build {
+			_ = os.Remove(download)
+			return fmt.Errorf("build %s does not match its hash", build[:12])
+		}
+		if err = os.Rename(download, cached); err != nil {
+			return err
+		}
+	}
+
+	if err := task.verifyBinary(cfg, cached, path.Join(cfg.CloudPath(config.BuildDirectory), build)); err != nil {
+		return fmt.Errorf("refusing build %s, keeping the current binary: %s", build[:12], err.Error())
+	}
+
+	// The binary is replaced with a rename so a running fuzzer is never left with a partially written file
+	tmp := localpath + ".tmp"
+	if err := copyFile(cached, tmp); err != nil {
+		return errors.New("failed to install build: " + err.Error())
+	}
+	if err := os.Chmod(tmp, 0770); err != nil {
+		return errors.New("failed to chmod target binary: " + err.Error())
+	}
+	if err := os.Rename(tmp, localpath); err != nil {
+		return errors.New("failed to install build: " + err.Error())
+	}
+	previous := localBuild(cfg)
+	if err := os.WriteFile(cfg.FilePath(config.LocalBuildFile), []byte(build), 0660); err != nil {
+		return err
+	}
+	task.pruneBuilds(cfg, build, previous)
+
+	log.Printf("[*] Switched to build %s", build[:12])
+	return nil
+}
+
+// pruneBuilds removes cached builds other than the current and previous ones and the build the pointer names, so
+// unpinning or rolling back once doesn't need to download the build again
+func (task *SyncTargetBinaryTask) pruneBuilds(cfg *config.Config, current, previous string) {
+	keep := map[string]bool{current: true, previous: true}
+	if pointer, err := task.readBuild(cfg, config.CloudBuildPointerFile); err == nil {
+		keep[pointer] = true
+	}
+
+	builds := cfg.WorkPath(config.BuildDirectory)
+	entries, _ := os.ReadDir(builds)
+	for _, entry := range entries {
+		if !entry.IsDir() && !keep[entry.Name()] {
+			_ = os.Remove(filepath.Join(builds, entry.Name()))
+		}
+	}
+}
+
+// localBuild returns the build the local `fuzzer` came from, or an empty string if it didn't come from a build
+func localBuild(cfg *config.Config) string {
+	content, err := os.ReadFile(cfg.FilePath(config.LocalBuildFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0770)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
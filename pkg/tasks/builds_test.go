@@ -0,0 +1,93 @@
+package tasks
+
+import (
+	"FuzzerMan/pkg/config"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSyncBuild(t *testing.T) {
+	bucket := t.TempDir()
+	cfg := &config.Config{WorkDirectory: t.TempDir()}
+	cfg.CloudStorage.BucketURL = "file://" + bucket
+	cfg.CloudStorage.Prefix = "campaign"
+
+	upload := func(name, content string) {
+		fn := filepath.Join(bucket, "campaign", name)
+		_ = os.MkdirAll(filepath.Dir(fn), 0770)
+		if err := os.WriteFile(fn, []byte(content), 0660); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash := func(content string) string {
+		sum := sha256.Sum256([]byte(content))
+		return hex.EncodeToString(sum[:])
+	}
+	expectBinary := func(content string) {
+		t.Helper()
+		if local, _ := os.ReadFile(cfg.FilePath(config.LocalFuzzerFile)); string(local) != content {
+			t.Errorf("expected fuzzer %q, got %q", content, local)
+		}
+		if build := localBuild(cfg); build != hash(content) {
+			t.Errorf("expected build %s, got %s", hash(content), build)
+		}
+	}
+
+	upload("builds/"+hash("v1"), "v1")
+	upload("builds/"+hash("v2"), "v2")
+	upload("build", hash("v2")+"\n")
+
+	task := &SyncTargetBinaryTask{}
+	if err := task.Initialize(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := task.Run(); err != nil {
+		t.Fatal(err)
+	}
+	expectBinary("v2")
+
+	// Pinning takes precedence over the pointer
+	upload("build.pin", hash("v1"))
+	if err := task.Run(); err != nil {
+		t.Fatal(err)
+	}
+	expectBinary("v1")
+
+	// A build that doesn't match its hash is refused and the current build is kept
+	upload("builds/"+hash("v3"), "tampered")
+	upload("build.pin", hash("v3"))
+	if err := task.Run(); err == nil {
+		t.Errorf("expected the tampered build to be refused")
+	}
+	expectBinary("v1")
+
+	// Only the current, previous and pointer builds stay cached
+	upload("builds/"+hash("v4"), "v4")
+	upload("build.pin", hash("v4"))
+	if err := task.Run(); err != nil {
+		t.Fatal(err)
+	}
+	expectBinary("v4")
+	upload("builds/"+hash("v5"), "v5")
+	upload("build.pin", hash("v5"))
+	if err := task.Run(); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(cfg.WorkPath(config.BuildDirectory))
+	var cached []string
+	for _, entry := range entries {
+		cached = append(cached, entry.Name())
+	}
+	if len(cached) != 3 {
+		t.Errorf("expected the v5, v4 and v2 builds to be cached, got %v", cached)
+	}
+	for _, version := range []string{"v2", "v4", "v5"} {
+		if _, err := os.Stat(filepath.Join(cfg.WorkPath(config.BuildDirectory), hash(version))); err != nil {
+			t.Errorf("expected build %s to be cached", version)
+		}
+	}
+}
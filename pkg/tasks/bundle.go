@@ -0,0 +1,334 @@
+package tasks
+
+import (
+	"FuzzerMan/pkg/config"
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// A target can be a bundle, `bundle.tar.gz` or `bundle.zip`, in place of the `fuzzer` binary for targets that need
+// shared libraries or data files next to them. Each version of the bundle is extracted into its own directory under
+// `bundles/` and the local `fuzzer` is a symlink to the bundle's entry point, so switching versions is atomic.
+
+var bundleNames = []string{"bundle.tar.gz", "bundle.zip"}
+
+// remoteBundle returns the key and attributes of the target's bundle, or a nil attributes if there is none
+func (task *SyncTargetBinaryTask) remoteBundle(cfg *config.Config) (string, *blob.Attributes, error) {
+	for _, name := range bundleNames {
+		key := path.Join(cfg.CloudStorage.Prefix, name)
+		attrs, err := task.cloud.FileInfo(key)
+		if err != nil && gcerrors.Code(err) == gcerrors.NotFound {
+			continue
+		} else if err != nil {
+			return key, nil, err
+		}
+		return key, attrs, nil
+	}
+	return "", nil, nil
+}
+
+// syncBundle extracts the bundle and points the local `fuzzer` at its entry point if the bundle is newer than the
+// installed version
+func (task *SyncTargetBinaryTask) syncBundle(cfg *config.Config, key string, attrs *blob.Attributes) error {
+	localpath := cfg.FilePath(config.LocalFuzzerFile)
+	if info, err := os.Lstat(localpath); err == nil && !attrs.ModTime.After(info.ModTime()) {
+		return nil
+	}
+
+	log.Printf("[*] Fetching updated target bundle.")
+	bundles, err := filepath.Abs(cfg.WorkPath(config.BundleDirectory))
+	if err != nil {
+		return err
+	}
+	download := filepath.Join(bundles, "download-"+path.Base(key))
+	defer func() { _ = os.Remove(download) }()
+	if err = task.cloud.DownloadSingle(key, download); err != nil {
+		return errors.New("failed to fetch target bundle: " + err.Error())
+	}
+	if err = task.verifyBinary(cfg, download, key); err != nil {
+		return fmt.Errorf("refusing target bundle, keeping the current one: %s", err.Error())
+	}
+
+	// Bundles are versioned by their hash, extracting into a temporary directory first means a bundle directory
+	// is always complete
+	version, err := fileSHA256(download)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(bundles, version)
+	if _, err = os.Stat(dir); os.IsNotExist(err) {
+		tmp := dir + ".tmp"
+		_ = os.RemoveAll(tmp)
+		if err = extractBundle(download, tmp); err != nil {
+			_ = os.RemoveAll(tmp)
+			return errors.New("failed to extract target bundle: " + err.Error())
+		}
+		if err = os.Rename(tmp, dir); err != nil {
+			return err
+		}
+	}
+
+	entryPoint := cfg.Fuzzer.EntryPoint
+	if entryPoint == "" {
+		entryPoint = "fuzzer"
+	}
+	binary, err := filepath.Abs(filepath.Join(dir, filepath.FromSlash(entryPoint)))
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(binary); err != nil || info.IsDir() {
+		return fmt.Errorf("target bundle has no entry point '%s'", entryPoint)
+	}
+	if err = os.Chmod(binary, 0770); err != nil {
+		return errors.New("failed to chmod target binary: " + err.Error())
+	}
+
+	previous, _ := bundleDirectory(cfg)
+	link := localpath + ".tmp"
+	_ = os.Remove(link)
+	if err = os.Symlink(binary, link); err != nil {
+		return err
+	}
+	if err = os.Rename(link, localpath); err != nil {
+		return errors.New("failed to replace target binary: " + err.Error())
+	}
+	_ = os.Remove(cfg.FilePath(config.LocalBuildFile))
+
+	// Only the current and previous versions are kept, the previous one may still be in use by a running fuzzer
+	entries, _ := os.ReadDir(bundles)
+	for _, entry := range entries {
+		fn := filepath.Join(bundles, entry.Name())
+		if entry.IsDir() && fn != dir && fn != previous {
+			_ = os.RemoveAll(fn)
+		}
+	}
+
+	log.Printf("[*] Updated target bundle (%s)", version[:12])
+	return nil
+}
+
+// bundleDirectory returns the directory of the bundle the local `fuzzer` belongs to, the bool is false if the
+// target isn't a bundle
+func bundleDirectory(cfg *config.Config) (string, bool) {
+	binary, err := os.Readlink(cfg.FilePath(config.LocalFuzzerFile))
+	if err != nil {
+		return "", false
+	}
+	bundles, err := filepath.Abs(filepath.Join(cfg.WorkDirectory, string(config.BundleDirectory)))
+	if err != nil || !insideDir(bundles, binary) {
+		return "", false
+	}
+	rel, _ := filepath.Rel(bundles, binary)
+	return filepath.Join(bundles, strings.Split(filepath.ToSlash(rel), "/")[0]), true
+}
+
+// bundleEnvironment adds the bundle's library directories to LD_LIBRARY_PATH, ahead of any that were already set.
+// FUZZERMAN_BUNDLE_DIR is set so the target can find its data files.
+func bundleEnvironment(env []string, dir string) []string {
+	if env == nil {
+		// An empty environment means the host's is inherited, which needs to be kept when adding to it
+		env = os.Environ()
+	}
+	paths := []string{dir, filepath.Join(dir, "lib")}
+	for _, v := range env {
+		// The last value is the one the target would have seen
+		if strings.HasPrefix(v, "LD_LIBRARY_PATH=") && v != "LD_LIBRARY_PATH=" {
+			paths = []string{dir, filepath.Join(dir, "lib"), strings.TrimPrefix(v, "LD_LIBRARY_PATH=")}
+		}
+	}
+	libraryPath := strings.Join(paths, string(os.PathListSeparator))
+	return append(env, "LD_LIBRARY_PATH="+libraryPath, "FUZZERMAN_BUNDLE_DIR="+dir)
+}
+
+// extractBundle extracts a tar.gz or zip archive into dir. Entries that would end up outside of dir are refused.
+func extractBundle(archivePath, dir string) error {
+	if err := extractArchive(archivePath, dir); err != nil {
+		return err
+	}
+	return checkBundleSymlinks(dir)
+}
+
+func extractArchive(archivePath, dir string) error {
+	if err := os.MkdirAll(dir, 0770); err != nil {
+		return err
+	}
+	fp, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = fp.Close() }()
+
+	if strings.HasSuffix(archivePath, ".zip") {
+		info, err := fp.Stat()
+		if err != nil {
+			return err
+		}
+		archive, err := zip.NewReader(fp, info.Size())
+		if err != nil {
+			return err
+		}
+		for _, f := range archive.File {
+			if err = extractZipEntry(f, dir); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	gz, err := gzip.NewReader(fp)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = gz.Close() }()
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		fn, err := bundlePath(dir, header.Name)
+		if err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(fn, 0770)
+		case tar.TypeReg:
+			err = writeBundleFile(fn, archive, header.FileInfo().Mode())
+		case tar.TypeSymlink:
+			err = bundleSymlink(dir, fn, header.Linkname)
+		case tar.TypeLink:
+			var target string
+			if target, err = bundlePath(dir, header.Linkname); err == nil {
+				err = os.Link(target, fn)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func extractZipEntry(f *zip.File, dir string) error {
+	fn, err := bundlePath(dir, f.Name)
+	if err != nil {
+		return err
+	}
+	if f.FileInfo().IsDir() {
+		return os.MkdirAll(fn, 0770)
+	}
+
+	reader, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = reader.Close() }()
+
+	if f.Mode()&os.ModeSymlink != 0 {
+		target, err := io.ReadAll(reader)
+		if err != nil {
+			return err
+		}
+		return bundleSymlink(dir, fn, string(target))
+	}
+	return writeBundleFile(fn, reader, f.Mode())
+}
+
+// bundlePath returns where an archive entry is extracted to, refusing entries outside of the bundle directory. The
+// check is lexical, so entries whose parent directory is a symlink extracted earlier are refused too.
+func bundlePath(dir, name string) (string, error) {
+	fn := filepath.Join(dir, filepath.FromSlash(name))
+	if !insideDir(dir, fn) {
+		return "", fmt.Errorf("bundle entry '%s' is outside of the bundle", name)
+	}
+	rel, _ := filepath.Rel(dir, filepath.Dir(fn))
+	parent := dir
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if part == "." {
+			continue
+		}
+		parent = filepath.Join(parent, part)
+		if info, err := os.Lstat(parent); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("bundle entry '%s' is within a symlink", name)
+		}
+	}
+	return fn, nil
+}
+
+func insideDir(dir, fn string) bool {
+	rel, err := filepath.Rel(dir, fn)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// bundleSymlink creates a symlink within the bundle such as `libfoo.so -> libfoo.so.1`, links pointing outside of
+// the bundle are refused
+func bundleSymlink(dir, fn, target string) error {
+	if filepath.IsAbs(target) {
+		return fmt.Errorf("bundle symlink '%s' has an absolute target", filepath.Base(fn))
+	}
+	if !insideDir(dir, filepath.Join(filepath.Dir(fn), target)) {
+		return fmt.Errorf("bundle symlink '%s' points outside of the bundle", filepath.Base(fn))
+	}
+	if err := os.MkdirAll(filepath.Dir(fn), 0770); err != nil {
+		return err
+	}
+	return os.Symlink(target, fn)
+}
+
+// checkBundleSymlinks refuses symlinks that resolve to outside of the bundle. Each symlink's target is only checked
+// lexically while extracting, a chain of them can still lead out (`b -> ../..` and `a -> b/..`).
+func checkBundleSymlinks(dir string) error {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	return filepath.WalkDir(dir, func(fn string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.Type()&fs.ModeSymlink == 0 {
+			return err
+		}
+		resolved, err := filepath.EvalSymlinks(fn)
+		if os.IsNotExist(err) {
+			// A dangling symlink doesn't lead anywhere
+			return nil
+		}
+		if err != nil || !insideDir(root, resolved) {
+			rel, _ := filepath.Rel(dir, fn)
+			return fmt.Errorf("bundle symlink '%s' points outside of the bundle", filepath.ToSlash(rel))
+		}
+		return nil
+	})
+}
+
+func writeBundleFile(fn string, reader io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(fn), 0770); err != nil {
+		return err
+	}
+	// Opening an existing symlink would write to wherever it points
+	if info, err := os.Lstat(fn); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("bundle entry '%s' replaces a symlink", filepath.Base(fn))
+	}
+	fp, err := os.OpenFile(fn, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm()|0600)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(fp, reader); err != nil {
+		_ = fp.Close()
+		return err
+	}
+	return fp.Close()
+}
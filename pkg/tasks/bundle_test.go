@@ -0,0 +1,104 @@
+package tasks
+
+import (
+	"FuzzerMan/pkg/config"
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type bundleEntry struct {
+	name, content, link string
+}
+
+func writeTestBundle(t *testing.T, fn string, entries []bundleEntry) {
+	_ = os.MkdirAll(filepath.Dir(fn), 0770)
+	fp, err := os.Create(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(fp)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		if e.link != "" {
+			_ = tw.WriteHeader(&tar.Header{Name: e.name, Typeflag: tar.TypeSymlink, Linkname: e.link, Mode: 0770})
+			continue
+		}
+		_ = tw.WriteHeader(&tar.Header{Name: e.name, Typeflag: tar.TypeReg, Mode: 0770, Size: int64(len(e.content))})
+		_, _ = tw.Write([]byte(e.content))
+	}
+	_ = tw.Close()
+	_ = gz.Close()
+	_ = fp.Close()
+}
+
+func TestSyncBundle(t *testing.T) {
+	bucket := t.TempDir()
+	cfg := &config.Config{WorkDirectory: t.TempDir()}
+	cfg.CloudStorage.BucketURL = "file://" + bucket
+	cfg.CloudStorage.Prefix = "campaign"
+	cfg.Fuzzer.EntryPoint = "bin/fuzzer"
+	cfg.Fuzzer.Environment = []string{"LD_LIBRARY_PATH=/opt/lib"}
+
+	writeTestBundle(t, filepath.Join(bucket, "campaign", "bundle.tar.gz"), []bundleEntry{
+		{name: "bin/fuzzer", content: "#!/bin/sh\n"},
+		{name: "lib/libtarget.so.1", content: "library"},
+		{name: "lib/libtarget.so", link: "libtarget.so.1"},
+	})
+
+	task := &SyncTargetBinaryTask{}
+	if err := task.Initialize(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := task.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, ok := bundleDirectory(cfg)
+	if !ok {
+		t.Fatalf("expected the fuzzer to be part of a bundle")
+	}
+	if content, err := os.ReadFile(filepath.Join(dir, "lib", "libtarget.so")); err != nil || string(content) != "library" {
+		t.Errorf("expected the library symlink to be extracted: %v", err)
+	}
+	if info, err := os.Stat(cfg.FilePath(config.LocalFuzzerFile)); err != nil || info.Mode()&0111 == 0 {
+		t.Errorf("expected an executable entry point: %v", err)
+	}
+
+	env := targetEnvironment(cfg)
+	expected := "LD_LIBRARY_PATH=" + dir + ":" + filepath.Join(dir, "lib") + ":/opt/lib"
+	found := false
+	for _, v := range env {
+		found = found || v == expected
+	}
+	if !found {
+		t.Errorf("expected %s in %v", expected, env)
+	}
+}
+
+func TestExtractBundleRefusesEscapes(t *testing.T) {
+	tests := map[string][]bundleEntry{
+		"path":    {{name: "../evil", content: "x"}},
+		"symlink": {{name: "link", link: "../../etc/passwd"}},
+		// Each link stays inside on its own, together they lead out
+		"chain":          {{name: "d1/d2/b", link: "../.."}, {name: "d1/d2/a", link: "b/.."}},
+		"chain reversed": {{name: "d1/d2/a", link: "b/.."}, {name: "d1/d2/b", link: "../.."}},
+		"through link":   {{name: "d1/up", link: ".."}, {name: "d1/up/evil", link: "../.."}},
+		"write via link": {{name: "d1/up", link: ".."}, {name: "d1/up/evil", content: "x"}},
+		"replace link":   {{name: "d1/x", link: "y"}, {name: "d1/x", content: "x"}},
+	}
+	for name, entries := range tests {
+		dir := filepath.Join(t.TempDir(), "bundles", "out")
+		fn := filepath.Join(filepath.Dir(dir), "bundle.tar.gz")
+		writeTestBundle(t, fn, entries)
+		if err := extractBundle(fn, dir); err == nil {
+			t.Errorf("%s: expected the bundle to be refused", name)
+		}
+		if _, err := os.Lstat(filepath.Join(filepath.Dir(dir), "evil")); err == nil {
+			t.Errorf("%s: an entry was written outside of the bundle", name)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package tasks
+
+import (
+	"FuzzerMan/pkg/config"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cgroup is a cgroup v2 the fuzzing processes of a single run are placed in, so the whole process tree shares one
+// set of limits rather than each process getting its own like `-rss_limit_mb`
+type cgroup struct {
+	path string
+	// baseOOMKills is memory.events' oom_kill counter when the cgroup was created, a cgroup left behind by an earlier
+	// run is reused rather than recreated
+	baseOOMKills int64
+}
+
+// cgroupName is derived from the work directory so every campaign and target gets its own cgroup
+func cgroupName(cfg *config.Config) string {
+	dir, err := filepath.Abs(cfg.WorkDirectory)
+	if err != nil {
+		dir = cfg.WorkDirectory
+	}
+	name := strings.Trim(strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r == ' ' {
+			return '-'
+		}
+		return r
+	}, dir), "-")
+	if name == "" {
+		return "fuzzerman"
+	}
+	return name
+}
+
+// createCgroup creates the campaign's cgroup under Cgroup.Parent with the configured limits, a nil cgroup is
+// returned when cgroups are not enabled
+func createCgroup(cfg *config.Config) (*cgroup, error) {
+	if !cfg.Cgroup.Enabled {
+		return nil, nil
+	}
+	parent := cfg.Cgroup.Parent
+	if parent == "" {
+		parent = config.DefaultCgroupParent
+	}
+	if err := os.Mkdir(parent, 0755); err != nil && !os.IsExist(err) {
+		return nil, fmt.Errorf("failed to create cgroup parent: %s", err.Error())
+	}
+	if _, err := os.Stat(filepath.Join(parent, "cgroup.subtree_control")); err != nil {
+		return nil, fmt.Errorf("%s is not a cgroup v2 directory: %s", parent, err.Error())
+	}
+
+	// Controllers have to be enabled for the parent's children before their limits can be set
+	var controllers []string
+	if cfg.Cgroup.MemoryMaxMB > 0 {
+		controllers = append(controllers, "+memory")
+	}
+	if cfg.Cgroup.CPUs > 0 {
+		controllers = append(controllers, "+cpu")
+	}
+	if cfg.Cgroup.PidsMax > 0 {
+		controllers = append(controllers, "+pids")
+	}
+	if len(controllers) > 0 {
+		if err := writeCgroupFile(parent, "cgroup.subtree_control", strings.Join(controllers, " ")); err != nil {
+			return nil, err
+		}
+	}
+
+	c := &cgroup{path: filepath.Join(parent, cgroupName(cfg))}
+	if err := os.Mkdir(c.path, 0755); err != nil && !os.IsExist(err) {
+		return nil, fmt.Errorf("failed to create cgroup: %s", err.Error())
+	}
+
+	if cfg.Cgroup.MemoryMaxMB > 0 {
+		if err := writeCgroupFile(c.path, "memory.max", strconv.FormatInt(int64(cfg.Cgroup.MemoryMaxMB)*1024*1024, 10)); err != nil {
+			return nil, err
+		}
+		// Without this the limit can be sidestepped by swapping, not every kernel has swap accounting though
+		_ = writeCgroupFile(c.path, "memory.swap.max", "0")
+	}
+	if cfg.Cgroup.CPUs > 0 {
+		const period = 100000
+		quota := int64(cfg.Cgroup.CPUs * period)
+		if err := writeCgroupFile(c.path, "cpu.max", fmt.Sprintf("%d %d", quota, period)); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Cgroup.PidsMax > 0 {
+		if err := writeCgroupFile(c.path, "pids.max", strconv.Itoa(cfg.Cgroup.PidsMax)); err != nil {
+			return nil, err
+		}
+	}
+
+	c.baseOOMKills, _ = c.readOOMKills()
+	return c, nil
+}
+
+// cgroupScript moves itself into the cgroup given as its first argument and then exec's the rest of its arguments,
+// exec keeps the pid the same so the target (or engine) starts within the cgroup and anything it forks can't escape it
+const cgroupScript = `echo $$ > "$1/cgroup.procs" || { echo "[!] Failed to enter cgroup $1" >&2; exit 125; }
+shift
+exec "$@"
+`
+
+// wrap rewrites cmd so it enters the cgroup before it is exec'd. It has to be the outermost wrapper, the sandbox
+// makes the cgroup filesystem read-only.
+func (c *cgroup) wrap(cmd *exec.Cmd) error {
+	if c == nil {
+		return nil
+	}
+	// The shell resolves the command from cmd.Dir rather than the current directory
+	binary, err := filepath.Abs(cmd.Path)
+	if err != nil {
+		return err
+	}
+	args := []string{"/bin/sh", "-c", cgroupScript, "cgroup", c.path, binary}
+	cmd.Args = append(args, cmd.Args[1:]...)
+	cmd.Path = "/bin/sh"
+	return nil
+}
+
+// OOMKills returns the number of processes killed by the cgroup's memory limit since it was created
+func (c *cgroup) OOMKills() int64 {
+	if c == nil {
+		return 0
+	}
+	kills, err := c.readOOMKills()
+	if err != nil {
+		return 0
+	}
+	return kills - c.baseOOMKills
+}
+
+func (c *cgroup) readOOMKills() (int64, error) {
+	content, err := os.ReadFile(filepath.Join(c.path, "memory.events"))
+	if err != nil {
+		return 0, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "oom_kill" {
+			return strconv.ParseInt(fields[1], 10, 64)
+		}
+	}
+	return 0, errors.New("no oom_kill counter in memory.events")
+}
+
+// remove kills anything left in the cgroup, such as workers orphaned by the engine, and removes it
+func (c *cgroup) remove() error {
+	if c == nil {
+		return nil
+	}
+	_ = writeCgroupFile(c.path, "cgroup.kill", "1")
+
+	// Killed processes take a moment to leave the cgroup, until then it can't be removed
+	var err error
+	for i := 0; i < 10; i++ {
+		if err = os.Remove(c.path); err == nil || os.IsNotExist(err) {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("failed to remove cgroup: %s", err.Error())
+}
+
+func writeCgroupFile(dir, name, value string) error {
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(value), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %s", name, err.Error())
+	}
+	return nil
+}
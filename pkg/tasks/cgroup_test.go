@@ -0,0 +1,78 @@
+package tasks
+
+import (
+	"FuzzerMan/pkg/config"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCreateCgroup(t *testing.T) {
+	parent := t.TempDir()
+	if err := os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{WorkDirectory: "/work/campaign"}
+	if group, err := createCgroup(cfg); group != nil || err != nil {
+		t.Fatalf("expected no cgroup when disabled, got %v (%v)", group, err)
+	}
+
+	cfg.Cgroup.Enabled = true
+	cfg.Cgroup.Parent = parent
+	cfg.Cgroup.MemoryMaxMB = 512
+	cfg.Cgroup.CPUs = 1.5
+	cfg.Cgroup.PidsMax = 64
+	group, err := createCgroup(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if group.path != filepath.Join(parent, "work-campaign") {
+		t.Errorf("unexpected cgroup path %s", group.path)
+	}
+
+	expected := map[string]string{
+		filepath.Join(parent, "cgroup.subtree_control"): "+memory +cpu +pids",
+		filepath.Join(group.path, "memory.max"):         "536870912",
+		filepath.Join(group.path, "cpu.max"):            "150000 100000",
+		filepath.Join(group.path, "pids.max"):           "64",
+	}
+	for fn, value := range expected {
+		if content, _ := os.ReadFile(fn); string(content) != value {
+			t.Errorf("expected %s to be %q, got %q", filepath.Base(fn), value, content)
+		}
+	}
+
+	events := "low 0\nhigh 0\nmax 3\noom 2\noom_kill 2\noom_group_kill 0\n"
+	if err = os.WriteFile(filepath.Join(group.path, "memory.events"), []byte(events), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if kills := group.OOMKills(); kills != 2 {
+		t.Errorf("expected 2 OOM kills, got %d", kills)
+	}
+}
+
+func TestCgroupWrap(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires /bin/sh")
+	}
+	group := &cgroup{path: t.TempDir()}
+	cmd := exec.Command("echo", "started")
+	if err := group.wrap(cmd); err != nil {
+		t.Fatal(err)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "started\n" {
+		t.Errorf("unexpected output %q", out)
+	}
+	// The process wrote its own pid before exec'ing the command, so it was in the cgroup from the start
+	if procs, _ := os.ReadFile(filepath.Join(group.path, "cgroup.procs")); strings.TrimSpace(string(procs)) != strconv.Itoa(cmd.Process.Pid) {
+		t.Errorf("expected cgroup.procs to hold %d, got %q", cmd.Process.Pid, procs)
+	}
+}
@@ -0,0 +1,141 @@
+package tasks
+
+import (
+	"FuzzerMan/pkg/cloudutil"
+	"FuzzerMan/pkg/config"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"time"
+)
+
+// mirrorCorpus mirrors the cloud corpus into the local corpus directory. It is unpacked from shards when
+// CorpusShards is enabled, or synced incrementally when CorpusManifest is.
+func mirrorCorpus(cloud *cloudutil.Client, cfg *config.Config) (*cloudutil.TransferResult, error) {
+	cloudCorpusPath := cfg.CloudPath(config.CorpusDirectory)
+	localCorpusPath := cfg.WorkPath(config.CorpusDirectory)
+	if sharded, err := shardedCorpus(cloud, cfg); err != nil {
+		return nil, err
+	} else if sharded {
+		return cloud.MirrorLocalShards(cfg.CloudPath(config.ShardDirectory), localCorpusPath, cfg.FilePath(config.LocalShardIndex))
+	}
+	if !cfg.CorpusManifest.Enabled {
+		return cloud.MirrorLocal(cloudCorpusPath, localCorpusPath)
+	}
+	return cloud.MirrorLocalIncremental(cloudCorpusPath, localCorpusPath, cfg.FilePath(config.CloudCorpusManifest),
+		cfg.FilePath(config.LocalCorpusManifestState), cfg.ManifestMaxAge())
+}
+
+// shardedCorpus is true once the merge task has written the corpus as shards, until then the per-input corpus is
+// still used so a campaign can switch over without losing its corpus
+func shardedCorpus(cloud *cloudutil.Client, cfg *config.Config) (bool, error) {
+	if !cfg.CorpusShards.Enabled {
+		return false, nil
+	}
+	shards, err := cloud.ListShards(cfg.CloudPath(config.ShardDirectory))
+	return len(shards) > 0, err
+}
+
+// uploadCorpus uploads new corpus files, as a delta shard once the corpus is sharded. Otherwise they are added to
+// the manifest so other instances fetch them on their next sync.
+func uploadCorpus(cloud *cloudutil.Client, cfg *config.Config, files []string) error {
+	localCorpusPath := cfg.WorkPath(config.CorpusDirectory)
+	if sharded, err := shardedCorpus(cloud, cfg); err != nil {
+		return err
+	} else if sharded {
+		_, err = cloud.UploadShard(localCorpusPath, files, cfg.CloudPath(config.ShardDirectory), cfg.CorpusShards.Format, cfg.FilePath(config.LocalShardIndex))
+		return err
+	}
+
+	result, err := cloud.Upload(localCorpusPath, files, cfg.CloudPath(config.CorpusDirectory))
+	if cfg.CorpusManifest.Enabled {
+		// Only the inputs that made it to the bucket go in the manifest
+		var uploaded []string
+		for _, key := range result.Succeeded {
+			uploaded = append(uploaded, path.Base(key))
+		}
+		if err := cloud.WriteManifestDelta(cfg.FilePath(config.CloudCorpusManifest), uploaded); err != nil {
+			log.Printf("[!] Failed to update corpus manifest: %s", err.Error())
+		}
+	}
+	return err
+}
+
+// writeCorpusManifest replaces the manifest with the merged corpus, listed is when the corpus was last listed
+func (task *CorpusMergeTask) writeCorpusManifest(corpusDir string, listed time.Time) error {
+	if !task.config.CorpusManifest.Enabled {
+		return nil
+	}
+	entries, err := os.ReadDir(corpusDir)
+	if err != nil {
+		return err
+	}
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			files = append(files, entry.Name())
+		}
+	}
+	return task.cloud.WriteManifest(task.config.FilePath(config.CloudCorpusManifest), files, listed)
+}
+
+// writeShards replaces the corpus shards with the merged corpus, merged holds the objects the merge started from.
+// Shards uploaded by other instances during the merge are unpacked into it first so their inputs aren't lost, and
+// only shards that made it into the merged corpus are replaced.
+func (task *CorpusMergeTask) writeShards(corpusDir string, startTime time.Time, merged map[string]bool) error {
+	cloudShardPath := task.config.CloudPath(config.ShardDirectory)
+	newObjects, err := task.cloud.NewObjects(cloudShardPath+"/", startTime)
+	if err != nil {
+		return fmt.Errorf("failed to get new shard list: %s", err.Error())
+	}
+	var newShards []string
+	for _, obj := range newObjects {
+		newShards = append(newShards, obj.Key)
+	}
+	if len(newShards) > 0 {
+		if _, err = task.cloud.DownloadShards(newShards, corpusDir); err != nil {
+			return fmt.Errorf("failed to copy new shards into merged corpus: %s", err.Error())
+		}
+		for _, key := range newShards {
+			merged[key] = true
+		}
+	}
+
+	// Until the first shard exists instances keep uploading to the per-input corpus. Inputs that weren't part of the
+	// merge are added to the shards, then the per-input corpus is removed. Inputs uploaded after this listing are
+	// moved by the next merge.
+	cloudCorpusPath := task.config.CloudPath(config.CorpusDirectory)
+	corpusObjects, err := task.cloud.NewObjects(cloudCorpusPath+"/", time.Time{})
+	if err != nil {
+		return fmt.Errorf("failed to list the per-input corpus: %s", err.Error())
+	}
+	var perInput, unmerged []string
+	for _, obj := range corpusObjects {
+		perInput = append(perInput, obj.Key)
+		if !merged[obj.Key] {
+			unmerged = append(unmerged, obj.Key)
+		}
+	}
+	if len(unmerged) > 0 {
+		if _, err = task.cloud.Download(unmerged, corpusDir); err != nil {
+			return fmt.Errorf("failed to copy the per-input corpus into the shards: %s", err.Error())
+		}
+	}
+
+	result, err := task.cloud.MirrorRemoteShards(corpusDir, cloudShardPath, task.config.CorpusShards.Format, task.config.MaxShardSize(), merged)
+	if err != nil {
+		return errors.New("corpus shard mirror failed: " + err.Error())
+	}
+	log.Printf("[-] Uploaded shards: %d || Deleted shards (remote): %d", len(result.Succeeded), len(result.Deleted))
+
+	if len(perInput) > 0 {
+		removed, err := task.cloud.Delete(perInput)
+		if err != nil {
+			return errors.New("failed to remove the per-input corpus: " + err.Error())
+		}
+		log.Printf("[-] Moved %d inputs from the per-input corpus into shards", len(removed.Deleted))
+	}
+	return nil
+}
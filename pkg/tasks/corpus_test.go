@@ -0,0 +1,52 @@
+package tasks
+
+import (
+	"FuzzerMan/pkg/cloudutil"
+	"FuzzerMan/pkg/config"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestWriteShardsMigratesCorpus(t *testing.T) {
+	bucket := t.TempDir()
+	cfg := &config.Config{WorkDirectory: t.TempDir()}
+	cfg.CloudStorage.BucketURL = "file://" + bucket
+	cfg.CloudStorage.Prefix = "campaign"
+	cfg.CorpusShards.Enabled = true
+	task := &CorpusMergeTask{config: cfg, cloud: cloudutil.NewClient(context.Background(), cfg.CloudStorage.BucketURL)}
+
+	// "gone" was merged away and "late" was uploaded after the merge listed the corpus
+	for _, name := range []string{"kept", "gone", "late"} {
+		fn := filepath.Join(bucket, "campaign", "corpus", name)
+		_ = os.MkdirAll(filepath.Dir(fn), 0770)
+		_ = os.WriteFile(fn, []byte(name), 0660)
+	}
+	corpusDir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(corpusDir, "kept"), []byte("kept"), 0660)
+	merged := map[string]bool{"campaign/corpus/kept": true, "campaign/corpus/gone": true}
+
+	if err := task.writeShards(corpusDir, time.Now(), merged); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(bucket, "campaign", "corpus")); len(entries) != 0 {
+		t.Errorf("expected the per-input corpus to be removed, got %d inputs", len(entries))
+	}
+
+	local := t.TempDir()
+	if _, err := task.cloud.MirrorLocalShards(cfg.CloudPath(config.ShardDirectory), local, filepath.Join(t.TempDir(), "index")); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(local)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	if len(names) != 2 || names[0] != "kept" || names[1] != "late" {
+		t.Errorf("unexpected sharded corpus %v", names)
+	}
+}
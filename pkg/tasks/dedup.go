@@ -0,0 +1,63 @@
+package tasks
+
+import (
+	"FuzzerMan/pkg/config"
+	"FuzzerMan/pkg/engine"
+	"encoding/json"
+	"gocloud.dev/blob"
+	"gocloud.dev/gcerrors"
+	"path"
+	"time"
+)
+
+// CrashSignature is the registry entry stored in the bucket for every unique crash seen by the campaign
+type CrashSignature struct {
+	Signature     string    `json:"signature"`
+	Sanitizer     string    `json:"sanitizer"`
+	CrashType     string    `json:"crash_type"`
+	Frames        []string  `json:"frames"`
+	FirstArtifact string    `json:"first_artifact"`
+	FirstSeen     time.Time `json:"first_seen"`
+	LastSeen      time.Time `json:"last_seen"`
+	Count         int       `json:"count"`
+}
+
+// registerCrash records a crash occurrence in the signature registry, returning true if this is the first time
+// the signature has been seen. There is no locking around the registry so two instances hitting a new crash at
+// the same moment may both report it, and concurrent updates to the counter may be lost.
+func (task *FuzzTask) registerCrash(report *engine.SanitizerReport, artifact string) (bool, *CrashSignature, error) {
+	sig := report.Signature(task.config.CrashDedup.StackDepth)
+	key := path.Join(task.config.CloudPath(config.SignatureDirectory), sig+".json")
+	opts := &blob.WriterOptions{CacheControl: "no-cache", ContentType: "application/json"}
+	now := time.Now().UTC()
+	cloud := task.uploadClient()
+
+	var entry CrashSignature
+	content, err := cloud.ReadFile(key, nil)
+	if err != nil {
+		if gcerrors.Code(err) != gcerrors.NotFound {
+			return false, nil, err
+		}
+
+		entry = CrashSignature{
+			Signature:     sig,
+			Sanitizer:     report.Sanitizer,
+			CrashType:     report.CrashType,
+			Frames:        report.TopFrames(task.config.CrashDedup.StackDepth),
+			FirstArtifact: artifact,
+			FirstSeen:     now,
+			LastSeen:      now,
+			Count:         1,
+		}
+		content, _ = json.MarshalIndent(entry, "", "  ")
+		return true, &entry, cloud.WriteFile(key, content, opts)
+	}
+
+	if err = json.Unmarshal(content, &entry); err != nil {
+		return false, nil, err
+	}
+	entry.Count++
+	entry.LastSeen = now
+	content, _ = json.MarshalIndent(entry, "", "  ")
+	return false, &entry, cloud.WriteFile(key, content, opts)
+}
@@ -3,7 +3,8 @@ package tasks
 import (
 	"FuzzerMan/pkg/cloudutil"
 	"FuzzerMan/pkg/config"
-	"bufio"
+	"FuzzerMan/pkg/engine"
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -14,55 +15,129 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
 type FuzzTask struct {
-	config  *config.Config
-	cloud   *cloudutil.Client
-	context context.Context
+	config    *config.Config
+	cloud     *cloudutil.Client
+	engine    engine.Engine
+	context   context.Context
+	reporting sync.WaitGroup
+	// reportingArtifacts are the artifacts with a crash report still running, they aren't pruned until it finishes
+	reportingArtifacts map[string]bool
+	reportingMu        sync.Mutex
+	// targets holds a task for each of the campaign's targets
+	targets targetTasks[*FuzzTask]
+	// binary checks for a new target binary while fuzzing. update is the new binary found during the last session
+	// and refusedUpdate one that failed to install, so it doesn't stop every session.
+	binary        *SyncTargetBinaryTask
+	update        string
+	refusedUpdate string
+	// maxTotalTime overrides Fuzzer.MaxTotalTime for a session restarted after a binary update, so the restarted
+	// session only runs for what was left of the original one
+	maxTotalTime int
 }
 
 func (task *FuzzTask) Initialize(ctx context.Context, cfg *config.Config) error {
+	var err error
 	task.config = cfg
 	task.context = ctx
 	task.cloud = cloudutil.NewClient(ctx, task.config.CloudStorage.BucketURL)
-
-	if info, err := os.Stat(cfg.FilePath(config.LocalFuzzerFile)); err != nil {
-		return errors.New(fmt.Sprintf("unable to stat target binary: %s", err.Error()))
-	} else {
-		// Check for executable bit to be set on any of owner/group/everyone
-		if info.Mode()&0111 == 0 {
-			return errors.New("target binary is not executable")
-		}
+	if task.engine, err = engine.Get(cfg.Fuzzer.Engine); err != nil {
+		return err
 	}
-
-	return nil
+	task.binary = &SyncTargetBinaryTask{config: cfg, cloud: task.cloud, context: ctx}
+	if len(localTargets(cfg)) > 0 {
+		// Each target's binary is checked when the target is first run
+		return nil
+	}
+	return checkTargetBinary(cfg)
 
 }
 
 func (task *FuzzTask) Run() error {
-	cloudCorpusPath := task.config.CloudPath(config.CorpusDirectory)
+	if targets := localTargets(task.config); len(targets) > 0 {
+		return task.runNextTarget(targets)
+	}
+
 	localCorpusPath := task.config.WorkPath(config.CorpusDirectory)
-	cloudLogPath := task.config.CloudPath(config.LogDirectory)
-	localLogPath := task.config.WorkPath(config.LogDirectory)
 
 	// Mirror the Corpus from the authority in the cloud into the local folder
-	if downloaded, deleted, err := task.cloud.MirrorLocal(cloudCorpusPath, localCorpusPath); err != nil {
-		return errors.New(fmt.Sprintf("corpus mirror failed: %s", err.Error()))
-	} else {
-		log.Printf("[-] Downloaded: %d || Deleted (local): %d", downloaded, deleted)
+	result, mirrorErr := mirrorCorpus(task.cloud, task.config)
+	var transferErr *cloudutil.TransferError
+	if errors.As(mirrorErr, &transferErr) {
+		// Fuzzing can go ahead without a few inputs, the next mirror tries them again
+		log.Printf("[!] Corpus mirror incomplete: %s", mirrorErr.Error())
+	} else if mirrorErr != nil {
+		return errors.New(fmt.Sprintf("corpus mirror failed: %s", mirrorErr.Error()))
+	}
+	log.Printf("[-] Downloaded: %d || Deleted (local): %d", len(result.Succeeded), len(result.Deleted))
+
+	// The local corpus now matches the cloud, so an empty one means the campaign hasn't got a corpus yet
+	if files, err := os.ReadDir(localCorpusPath); mirrorErr == nil && err == nil && len(files) == 0 {
+		if err = task.bootstrapCorpus(); err != nil {
+			log.Printf("[!] Failed to bootstrap corpus from seeds: %s", err.Error())
+		}
+	}
+
+	if err := task.context.Err(); err != nil {
+		return err
+	}
+
+	// The session is restarted, for the rest of its time, whenever the target binary is updated while fuzzing
+	defer func() { task.maxTotalTime = 0 }()
+	deadline := time.Now().Add(time.Duration(task.config.Fuzzer.MaxTotalTime) * time.Second)
+	for {
+		if err := task.runSession(); err != nil {
+			return err
+		}
+
+		if task.context.Err() != nil {
+			// Let any crash reports from this run finish before the process exits
+			task.reporting.Wait()
+			return task.context.Err()
+		}
+		if task.update == "" {
+			return nil
+		}
+
+		if err := task.binary.syncBinary(task.config); err != nil {
+			log.Printf("[!] Failed to update target binary: %s", err.Error())
+			task.refusedUpdate = task.update
+		}
+		if task.config.Fuzzer.MaxTotalTime > 0 {
+			remaining := int(time.Until(deadline).Seconds())
+			if remaining <= 0 {
+				return nil
+			}
+			task.maxTotalTime = remaining
+		}
+		log.Printf("[*] Restarting fuzzer")
 	}
+}
+
+// runSession runs the fuzzer once and uploads its log, stats and new files
+func (task *FuzzTask) runSession() error {
+	cloudLogPath := task.config.CloudPath(config.LogDirectory)
+	localLogPath := task.config.WorkPath(config.LogDirectory)
 
 	startTime := time.Now()
 	timestamp := startTime.UTC().Format("2006-01-02-150405.00000")
 	logFilename := fmt.Sprintf("%s.log.txt", timestamp)
-	if err := task.RunFuzzer(logFilename); err != nil {
+	statsFilename := fmt.Sprintf("%s.stats.json", timestamp)
+	uploadedLog, err := task.RunFuzzer(logFilename, statsFilename)
+	if err != nil {
 		return err
 	}
 
-	log.Printf("[*] Uploading log: %s", logFilename)
-	if err := task.cloud.Upload(localLogPath, []string{logFilename}, cloudLogPath); err != nil {
+	if task.context.Err() != nil {
+		log.Printf("[*] Fuzzer stopped, uploading results before shutting down")
+	}
+
+	log.Printf("[*] Uploading log: %s", uploadedLog)
+	if _, err := task.uploadClient().Upload(localLogPath, []string{uploadedLog, statsFilename}, cloudLogPath); err != nil {
 		log.Printf("[!] %s", err.Error())
 	}
 
@@ -72,82 +147,244 @@ func (task *FuzzTask) Run() error {
 	if err := task.UploadNewArtifacts(startTime); err != nil {
 		log.Printf("[!] %s", err.Error())
 	}
+	if err := task.UploadNewCrashMetadata(startTime); err != nil {
+		log.Printf("[!] %s", err.Error())
+	}
+
+	task.pruneWorkDirectory(startTime)
 	return nil
 }
 
+// runNextTarget runs a single session for the next target in turn, so each target gets an equal share of the time
+func (task *FuzzTask) runNextTarget(targets []*config.Config) error {
+	cfg := targets[nextTurn(task.config.FilePath(config.LocalTargetTurnFile))%len(targets)]
+	target, err := task.targets.get(task.context, cfg, func() *FuzzTask { return &FuzzTask{} })
+	if err != nil {
+		return fmt.Errorf("target %s: %s", cfg.Target, err.Error())
+	}
+	// MultiFuzzerMan shortens MaxTotalTime as the end of a campaign's time approaches
+	target.config.Fuzzer.MaxTotalTime = task.config.Fuzzer.MaxTotalTime
+
+	log.Printf("[*] Fuzzing target: %s", cfg.Target)
+	err = target.Run()
+	if task.context.Err() != nil {
+		// Earlier runs of the other targets may still be reporting crashes
+		for _, t := range task.targets.tasks {
+			t.reporting.Wait()
+		}
+	}
+	return err
+}
+
+// uploadClient returns the client results should be uploaded with. Once the task's context has been cancelled the
+// results of the final run still need to reach the bucket, so a client without the cancelled context is returned.
+func (task *FuzzTask) uploadClient() *cloudutil.Client {
+	if task.context.Err() == nil {
+		return task.cloud
+	}
+	return cloudutil.NewClient(context.Background(), task.config.CloudStorage.BucketURL)
+}
+
 func (task *FuzzTask) writeLogHeader(writer io.Writer) (err error) {
 	instance := task.config.InstanceId
-	metadata := "" // not doing anything with this yet
+	metadata := ""
+	if build := localBuild(task.config); build != "" {
+		metadata = fmt.Sprintf("build: %s", build)
+	}
 	header := []byte(fmt.Sprintf("%s\n%s\n=====\n", instance, metadata))
 	_, err = writer.Write(header)
 	return
 }
 
-func (task *FuzzTask) RunFuzzer(logFilename string) error {
-	localArtifactPath := task.config.WorkPath(config.ArtifactDirectory)
-	localCorpusPath := task.config.WorkPath(config.CorpusDirectory)
-	targetBinaryPath := task.config.FilePath(config.LocalFuzzerFile)
+// RunFuzzer runs a single fuzzing session, the target's output is written to logFilename and the parsed
+// progress stats to statsFilename, both in the local log directory. The log is compressed once the session has
+// exited, the name of the log to upload is returned, it is logFilename itself if compressing it failed.
+func (task *FuzzTask) RunFuzzer(logFilename, statsFilename string) (string, error) {
 	localLogPath := task.config.WorkPath(config.LogDirectory)
-	var args []string
-	args = append(args, fmt.Sprintf("-fork=%d", task.config.Fuzzer.ForkCount))
-	args = append(args, fmt.Sprintf("-max_total_time=%d", task.config.Fuzzer.MaxTotalTime))
-	args = append(args, fmt.Sprintf("-artifact_prefix=%s/", localArtifactPath))
-	args = append(args, task.config.Fuzzer.Arguments...)
-	args = append(args, localCorpusPath)
-
-	expectedDuration := time.Duration(task.config.Fuzzer.MaxTotalTime) * time.Second
-	log.Printf("[*] Fuzzing for %d minutes. (%s)", int(expectedDuration.Minutes()), logFilename)
-	cmd := exec.CommandContext(task.context, targetBinaryPath, args...)
-	if task.config.Fuzzer.IncludeHostEnv {
-		cmd.Env = os.Environ()
+	logFilePath := filepath.Join(localLogPath, logFilename)
+	opts := engineOptions(task.config)
+	opts.Dictionary = task.nextDictionary()
+	task.update = ""
+	if task.maxTotalTime > 0 {
+		opts.MaxTotalTime = task.maxTotalTime
+	}
+	outfile, err := os.OpenFile(logFilePath, os.O_WRONLY|os.O_CREATE, 0660)
+	if err != nil {
+		return logFilename, err
 	}
-	cmd.Env = append(cmd.Env, task.config.Fuzzer.Environment...)
+	defer func() { _ = outfile.Close() }()
+	_ = task.writeLogHeader(outfile)
 
-	//libFuzzer prints its info to stderr only
-	outPipe, err := cmd.StderrPipe()
+	stats := NewStatsCollector(task.engine, task.config.InstanceId, logFilename, time.Now())
+	stats.Stats.Build = localBuild(task.config)
+	cmds, err := task.engine.FuzzCommands(opts, io.MultiWriter(outfile, stats))
 	if err != nil {
-		return err
+		return logFilename, err
+	}
+	// The metadata records the command lines the engine built, not the sandbox wrapping them
+	var argv [][]string
+	for _, cmd := range cmds {
+		argv = append(argv, cmd.Args)
+		if err = sandboxCommand(task.config, cmd); err != nil {
+			return logFilename, fmt.Errorf("failed to sandbox fuzzer: %s", err.Error())
+		}
+	}
+	group, err := createCgroup(task.config)
+	if err != nil {
+		return logFilename, err
+	}
+	defer func() {
+		if err := group.remove(); err != nil {
+			log.Printf("[!] %s", err.Error())
+		}
+	}()
+	for _, cmd := range cmds {
+		if err = group.wrap(cmd); err != nil {
+			return logFilename, err
+		}
 	}
 
-	if err := cmd.Start(); err != nil {
-		return err
+	expectedDuration := time.Duration(opts.MaxTotalTime) * time.Second
+	log.Printf("[*] Fuzzing for %d minutes. (%s)", int(expectedDuration.Minutes()), logFilename)
+	if opts.Dictionary != "" {
+		log.Printf("[*] Using dictionary: %s", filepath.Base(opts.Dictionary))
+	}
+	for i, cmd := range cmds {
+		if err := cmd.Start(); err != nil {
+			for _, started := range cmds[:i] {
+				_ = started.Process.Kill()
+				_ = started.Wait()
+			}
+			return logFilename, err
+		}
 	}
 
-	logFilePath := filepath.Join(localLogPath, logFilename)
-	outfile, err := os.OpenFile(logFilePath, os.O_WRONLY|os.O_CREATE, 0660)
+	exited := make(chan struct{})
+	updated := make(chan string, 1)
+	go task.interruptOnCancel(cmds, exited)
+	go task.watchBinary(cmds, exited, updated)
+	for _, cmd := range cmds {
+		_ = cmd.Wait()
+	}
+	close(exited)
+	task.update = <-updated
+	_ = outfile.Close()
+
+	// The crashes are collected from the log before it is compressed, the stats and crash metadata point at the log
+	// that is uploaded
+	content, readErr := os.ReadFile(logFilePath)
+	uploadedLog, err := compressLog(localLogPath, logFilename)
 	if err != nil {
-		return err
+		log.Printf("[!] Failed to compress log: %s", err.Error())
 	}
-	defer func() { _ = outfile.Close() }()
-	_ = task.writeLogHeader(outfile)
+	stats.Stats.LogFile = uploadedLog
 
-	buf := make([]byte, 2048)
-	for {
-		n, err := outPipe.Read(buf)
-		if n > 0 {
-			_, _ = outfile.Write(buf[:n])
+	exitCode := cmds[0].ProcessState.ExitCode()
+	if stats.Stats.CgroupOOMKills = group.OOMKills(); stats.Stats.CgroupOOMKills > 0 {
+		log.Printf("[!] Cgroup memory limit killed %d processes", stats.Stats.CgroupOOMKills)
+	}
+	stats.Finish(exitCode)
+	if err := stats.WriteFile(filepath.Join(localLogPath, statsFilename)); err != nil {
+		log.Printf("[!] Failed to write stats: %s", err.Error())
+	} else {
+		final := stats.Stats.Final
+		log.Printf("[-] Runs: %d || Coverage: %d || Corpus: %d || Crashes: %d", final.Runs, final.Coverage, final.Corpus, final.Crashes)
+	}
+
+	var crashes []engine.Crash
+	if readErr != nil {
+		log.Printf("[!] Failed to read %s: %s", logFilePath, readErr.Error())
+	} else if crashes, err = task.engine.Collect(opts, content); err != nil {
+		log.Printf("[!] Failed to collect %s output: %s", task.engine.Name(), err.Error())
+	}
+	if len(crashes) > 0 {
+		task.writeCrashMetadata(argv, cmds[0].Env, uploadedLog, exitCode, crashes)
+	}
+
+	switch task.engine.ExitStatus(exitCode) {
+	case engine.ExitResourceLimit:
+		log.Printf("[*] Killed by %s (OOM/Timeout)", task.engine.Name())
+	case engine.ExitCrash:
+		log.Printf("[*] Got a crash")
+		if len(crashes) == 0 {
+			log.Printf("[!] Unable find artifact file in %s", logFilePath)
 		}
-		if err != nil {
-			break
+	}
+
+	// Crashes are reported regardless of the exit status, with -ignore_crashes=1 in fork mode the run can find
+	// crashes without exiting
+	if len(crashes) > 0 {
+		log.Printf("[*] Crashes found: %d", len(crashes))
+		task.reportCrashAsync(logFilename, crashes)
+	}
+	return uploadedLog, nil
+}
+
+// interruptOnCancel interrupts the fuzzing processes when the task's context is cancelled
+func (task *FuzzTask) interruptOnCancel(cmds []*exec.Cmd, exited <-chan struct{}) {
+	select {
+	case <-task.context.Done():
+		log.Printf("[*] Interrupting fuzzer")
+		task.interrupt(cmds, exited)
+	case <-exited:
+	}
+}
+
+// watchBinary polls for a new target binary while the fuzzer runs and interrupts the fuzzer when there is one. The
+// new binary is sent on updated, which is closed once watching stops.
+func (task *FuzzTask) watchBinary(cmds []*exec.Cmd, exited <-chan struct{}, updated chan<- string) {
+	defer close(updated)
+	interval := task.config.UpdateCheckInterval()
+	if interval == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	task.watchBinaryTicks(ticker.C, cmds, exited, updated)
+}
+
+// watchBinaryTicks checks for a new target binary on every tick until the fuzzer exits or is interrupted
+func (task *FuzzTask) watchBinaryTicks(ticks <-chan time.Time, cmds []*exec.Cmd, exited <-chan struct{}, updated chan<- string) {
+	for {
+		select {
+		case <-ticks:
+			update, err := task.binary.pendingUpdate(task.config)
+			if err != nil {
+				log.Printf("[!] Failed to check for a new target binary: %s", err.Error())
+				continue
+			}
+			if update == "" || update == task.refusedUpdate {
+				continue
+			}
+			log.Printf("[*] New target binary available, stopping fuzzer")
+			updated <- update
+			task.interrupt(cmds, exited)
+			return
+		case <-task.context.Done():
+			return
+		case <-exited:
+			return
 		}
 	}
-	_ = outfile.Close()
-	_ = cmd.Wait()
+}
 
-	switch cmd.ProcessState.ExitCode() {
-	case 77:
-		// This is usually an OOM/Timeout "crash"
-		log.Printf("[*] Killed by libFuzzer (OOM/Timeout)")
-	case 1:
-		log.Printf("[*] Got a crash")
-		go task.ReportCrash(logFilePath)
+// interrupt interrupts the fuzzing processes rather than killing them outright so they can exit cleanly. They are
+// only killed if still running after half the shutdown grace period.
+func (task *FuzzTask) interrupt(cmds []*exec.Cmd, exited <-chan struct{}) {
+	for _, cmd := range cmds {
+		_ = cmd.Process.Signal(os.Interrupt)
+	}
+	select {
+	case <-exited:
+	case <-time.After(task.config.GracePeriod() / 2):
+		for _, cmd := range cmds {
+			_ = cmd.Process.Kill()
+		}
 	}
-	return nil
 }
 
 func (task *FuzzTask) UploadNewCorpus(startTime time.Time) error {
 	localCorpusPath := task.config.WorkPath(config.CorpusDirectory)
-	cloudCorpusPath := task.config.CloudPath(config.CorpusDirectory)
 	newCorpus, err := newFilesSince(localCorpusPath, startTime)
 	if err != nil {
 		return err
@@ -155,7 +392,7 @@ func (task *FuzzTask) UploadNewCorpus(startTime time.Time) error {
 
 	if len(newCorpus) > 0 {
 		log.Printf("[*] New Corpus: %d", len(newCorpus))
-		if err = task.cloud.Upload(localCorpusPath, newCorpus, cloudCorpusPath); err != nil {
+		if err = uploadCorpus(task.uploadClient(), task.config, newCorpus); err != nil {
 			return err
 		}
 	}
@@ -183,7 +420,7 @@ func (task *FuzzTask) UploadNewArtifacts(startTime time.Time) error {
 
 	if len(newArtifacts) > 0 {
 		log.Printf("[*] New Artifacts: %d", len(newArtifacts))
-		if err = task.cloud.Upload(localArtifactPath, newArtifacts, cloudArtifactPath); err != nil {
+		if _, err = task.uploadClient().Upload(localArtifactPath, newArtifacts, cloudArtifactPath); err != nil {
 			return err
 		}
 	}
@@ -191,49 +428,149 @@ func (task *FuzzTask) UploadNewArtifacts(startTime time.Time) error {
 	return nil
 }
 
-func (task *FuzzTask) ReportCrash(logfilePath string) {
-	if task.config.ReportingEndpoint == "" {
-		return
+func (task *FuzzTask) reportCrashAsync(logFilename string, crashes []engine.Crash) {
+	task.reportingMu.Lock()
+	if task.reportingArtifacts == nil {
+		task.reportingArtifacts = make(map[string]bool)
+	}
+	for _, crash := range crashes {
+		task.reportingArtifacts[crash.Artifact] = true
 	}
+	task.reportingMu.Unlock()
+
+	task.reporting.Add(1)
+	go func() {
+		defer task.reporting.Done()
+		for _, crash := range crashes {
+			task.reportSingleCrash(logFilename, crash)
+			task.reportingMu.Lock()
+			delete(task.reportingArtifacts, crash.Artifact)
+			task.reportingMu.Unlock()
+		}
+	}()
+}
 
-	// first we need the artifact
-	targetString := "Test unit written to "
-	artifact := ""
+// isReporting returns true while the artifact's crash report is running
+func (task *FuzzTask) isReporting(artifact string) bool {
+	if artifact == "" {
+		return false
+	}
+	task.reportingMu.Lock()
+	defer task.reportingMu.Unlock()
+	return task.reportingArtifacts[artifact]
+}
 
-	logReader, err := os.OpenFile(logfilePath, os.O_RDONLY, 0660)
+// ReportCrash finds every crash in a previous run's log and reports each of them separately along with the part of
+// the log that belongs to it. The log can be compressed (`.log.txt.gz`) as it is after being uploaded.
+func (task *FuzzTask) ReportCrash(logfilePath string) {
+	content, err := readLog(logfilePath)
 	if err != nil {
-		log.Printf("[!] Failed to open %s: %s", logfilePath, err.Error())
+		log.Printf("[!] Failed to read %s: %s", logfilePath, err.Error())
 		return
 	}
-	defer func() { _ = logReader.Close() }()
 
-	scanner := bufio.NewScanner(logReader)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.Contains(line, targetString) {
-			artifact = strings.Split(line, targetString)[1]
-			artifact = filepath.Base(strings.TrimSpace(artifact))
-			break
+	crashes, err := task.engine.Collect(engineOptions(task.config), content)
+	if err != nil || len(crashes) == 0 {
+		log.Printf("[!] Unable find artifact file in %s", logfilePath)
+		return
+	}
+	task.reportCrashes(strings.TrimSuffix(filepath.Base(logfilePath), ".gz"), crashes)
+}
+
+func (task *FuzzTask) reportCrashes(logFilename string, crashes []engine.Crash) {
+	for _, crash := range crashes {
+		task.reportSingleCrash(logFilename, crash)
+	}
+}
+
+func (task *FuzzTask) reportSingleCrash(logFilename string, crash engine.Crash) {
+	fields := make(map[string]io.Reader)
+	artifactPath := filepath.Join(task.config.WorkPath(config.ArtifactDirectory), crash.Artifact)
+	if len(crash.Excerpt) == 0 {
+		// The engine didn't log the target's output, it is run again here rather than while the session is collected
+		excerpt, err := captureCrashOutput(task.context, task.engine, task.config, artifactPath)
+		if err != nil {
+			log.Printf("[!] Failed to capture output of %s: %s", crash.Artifact, err.Error())
 		}
+		crash.Excerpt = excerpt
 	}
-	if artifact == "" {
-		log.Printf("[!] Unable find artifact file in %s", logfilePath)
+	report := engine.ParseSanitizerReport(crash.Excerpt)
+
+	// Duplicates are dropped before they are reproduced, so a flood of the same crash doesn't run the target for each
+	if task.config.CrashDedup.Enabled {
+		if report == nil {
+			log.Printf("[!] Unable to find sanitizer report for %s, skipping deduplication", crash.Artifact)
+		} else {
+			isNew, entry, err := task.registerCrash(report, crash.Artifact)
+			if err != nil {
+				log.Printf("[!] Failed to update crash signature registry: %s", err.Error())
+			} else if !isNew {
+				log.Printf("[-] Duplicate crash %s (%s: %s) seen %d times", entry.Signature[:12], entry.Sanitizer, entry.CrashType, entry.Count)
+				if err = task.markDuplicateCrash(crash.Artifact, entry.Signature); err != nil {
+					log.Printf("[!] Failed to mark duplicate crash(%s): %s", crash.Artifact, err.Error())
+				}
+				return
+			}
+			fields["signature"] = strings.NewReader(report.Signature(task.config.CrashDedup.StackDepth))
+		}
+	}
+
+	reproducible := true
+	if task.config.Reproduce.Enabled {
+		result, err := ReproduceCrash(task.context, task.engine, task.config, artifactPath)
+		if err != nil {
+			log.Printf("[!] Failed to reproduce %s: %s", crash.Artifact, err.Error())
+		} else {
+			log.Printf("[-] Reproduced %s: %s", crash.Artifact, result)
+			if err = saveReproduction(task.config, crash.Artifact, result); err != nil {
+				log.Printf("[!] Failed to save verdict(%s): %s", crash.Artifact, err.Error())
+			}
+			reproducible = result.Verdict != VerdictNonReproducible
+			if !reproducible && task.config.Reproduce.SkipNonReproducible {
+				return
+			}
+			fields["verdict"] = strings.NewReader(string(result.Verdict))
+		}
+	}
+
+	if task.config.Minimize.Enabled && reproducible {
+		// Engines that can't minimize a single crash report it without a minimized input
+		minimizedPath, err := task.minimizeCrash(crash.Artifact)
+		if err == nil {
+			if minimizedReader, err := os.Open(minimizedPath); err == nil {
+				defer func() { _ = minimizedReader.Close() }()
+				fields["minimized"] = minimizedReader
+			}
+		} else if !errors.Is(err, engine.ErrUnsupported) {
+			log.Printf("[!] Failed to minimize %s: %s", crash.Artifact, err.Error())
+		}
+	}
+
+	if task.config.ReportingEndpoint == "" {
 		return
 	}
-	// Reset the log file descriptor so we can reuse it for the upload
-	_, _ = logReader.Seek(0, 0)
 
-	artifactReader, err := os.Open(filepath.Join(task.config.WorkPath(config.ArtifactDirectory), artifact))
+	artifactReader, err := os.Open(artifactPath)
 	if err != nil {
 		log.Printf("[!] Failed to open artifact file: %s", err.Error())
 		return
 	}
 	defer func() { _ = artifactReader.Close() }()
 
-	if err = MultipartFileUpload(&http.Client{Timeout: 5 * time.Minute}, task.config.ReportingEndpoint, map[string]io.Reader{
-		"log":      logReader,
-		"artifact": artifactReader,
-	}); err != nil {
+	log.Printf("[*] Reporting crash: %s", crash.Artifact)
+	if metadata, err := os.Open(crashMetadataPath(task.config, crash.Artifact)); err == nil {
+		defer func() { _ = metadata.Close() }()
+		fields["metadata"] = metadata
+	}
+	if task.config.Target != "" {
+		fields["target"] = strings.NewReader(task.config.Target)
+	}
+	if build := localBuild(task.config); build != "" {
+		fields["build"] = strings.NewReader(build)
+	}
+	fields["log"] = NamedReader{Reader: bytes.NewReader(crash.Excerpt), Filename: logFilename}
+	fields["artifact"] = artifactReader
+	if err = MultipartFileUpload(&http.Client{Timeout: 5 * time.Minute}, task.config.ReportingEndpoint, fields); err != nil {
 		log.Printf("[!] Crash report failed: %s", err.Error())
 	}
 }
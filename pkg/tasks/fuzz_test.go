@@ -1,9 +1,14 @@
 package tasks
 
 import (
+	"FuzzerMan/pkg/cloudutil"
 	"FuzzerMan/pkg/config"
+	"context"
+	"os"
+	"os/exec"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestReportCrash(t *testing.T) {
@@ -35,3 +40,71 @@ func TestReportCrash(t *testing.T) {
 		task.ReportCrash(filepath.Join(task.config.WorkPath(config.LogDirectory), logfn))
 	}
 }
+
+func TestWatchBinary(t *testing.T) {
+	bucket := t.TempDir()
+	cfg := &config.Config{WorkDirectory: t.TempDir(), ShutdownGracePeriod: 2}
+	cfg.CloudStorage.BucketURL = "file://" + bucket
+	cfg.CloudStorage.Prefix = "campaign"
+
+	remote := filepath.Join(bucket, "campaign", "fuzzer")
+	_ = os.MkdirAll(filepath.Dir(remote), 0770)
+	if err := os.WriteFile(remote, []byte("v1"), 0660); err != nil {
+		t.Fatal(err)
+	}
+	task := &FuzzTask{config: cfg, context: context.Background()}
+	task.cloud = cloudutil.NewClient(task.context, cfg.CloudStorage.BucketURL)
+	task.binary = &SyncTargetBinaryTask{config: cfg, cloud: task.cloud, context: task.context}
+	if err := task.binary.syncBinary(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if update, err := task.binary.pendingUpdate(cfg); err != nil || update != "" {
+		t.Fatalf("expected no pending update, got %q (%v)", update, err)
+	}
+
+	start := func() (*exec.Cmd, chan time.Time, chan struct{}, chan string) {
+		cmd := exec.Command("sleep", "30")
+		if err := cmd.Start(); err != nil {
+			t.Skip(err)
+		}
+		ticks := make(chan time.Time)
+		exited := make(chan struct{})
+		updated := make(chan string, 1)
+		go func() {
+			defer close(updated)
+			task.watchBinaryTicks(ticks, []*exec.Cmd{cmd}, exited, updated)
+		}()
+		return cmd, ticks, exited, updated
+	}
+
+	next := time.Now().Add(time.Minute)
+	if err := os.WriteFile(remote, []byte("v2"), 0660); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.Chtimes(remote, next, next)
+	cmd, ticks, exited, updated := start()
+	ticks <- time.Now()
+	// The fuzzer is interrupted, otherwise this waits for the whole sleep
+	_ = cmd.Wait()
+	close(exited)
+	update := <-updated
+	if update == "" {
+		t.Fatalf("expected the new binary to stop the fuzzer")
+	}
+	if cmd.ProcessState.String() != "signal: interrupt" {
+		t.Errorf("expected the fuzzer to be interrupted, got %s", cmd.ProcessState)
+	}
+
+	// A binary that failed to install doesn't stop the fuzzer again. The second tick is only received once the first
+	// has been checked.
+	task.refusedUpdate = update
+	cmd, ticks, exited, updated = start()
+	ticks <- time.Now()
+	ticks <- time.Now()
+	_ = cmd.Process.Kill()
+	_ = cmd.Wait()
+	close(exited)
+	if update = <-updated; update != "" {
+		t.Errorf("expected the refused binary to be ignored, got %q", update)
+	}
+}
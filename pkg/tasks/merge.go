@@ -3,6 +3,7 @@ package tasks
 import (
 	"FuzzerMan/pkg/cloudutil"
 	"FuzzerMan/pkg/config"
+	"FuzzerMan/pkg/engine"
 	"context"
 	"errors"
 	"fmt"
@@ -11,7 +12,6 @@ import (
 	"gocloud.dev/gcerrors"
 	"log"
 	"os"
-	"os/exec"
 	"strings"
 	"time"
 )
@@ -19,7 +19,9 @@ import (
 type CorpusMergeTask struct {
 	config  *config.Config
 	cloud   *cloudutil.Client
+	engine  engine.Engine
 	context context.Context
+	targets targetTasks[*CorpusMergeTask]
 }
 
 func (task *CorpusMergeTask) Initialize(ctx context.Context, cfg *config.Config) error {
@@ -27,6 +29,9 @@ func (task *CorpusMergeTask) Initialize(ctx context.Context, cfg *config.Config)
 	task.config = cfg
 	task.context = ctx
 	task.cloud = cloudutil.NewClient(ctx, task.config.CloudStorage.BucketURL)
+	if task.engine, err = engine.Get(cfg.Fuzzer.Engine); err != nil {
+		return err
+	}
 
 	// Ensure the merge lock exists and the expected Cache-Control value
 	lockAttrs, err := task.cloud.FileInfo(task.config.FilePath(config.MergeLockFile))
@@ -77,31 +82,59 @@ func (task *CorpusMergeTask) ShouldMerge() bool {
 }
 
 func (task *CorpusMergeTask) Run() error {
+	if targets := localTargets(task.config); len(targets) > 0 {
+		// Each target has its own corpus and merge lock
+		return task.targets.runAll(task.context, targets, func() *CorpusMergeTask { return &CorpusMergeTask{} })
+	}
 	if !task.ShouldMerge() {
 		return nil
 	}
 	startTime := time.Now()
 	localCorpusPath := task.config.WorkPath(config.CorpusDirectory)
 	cloudCorpusPath := task.config.CloudPath(config.CorpusDirectory)
+	cloudShardPath := task.config.CloudPath(config.ShardDirectory)
 
 	log.Println("[*] Creating temporary corpus directory")
 	tempCorpus := task.config.WorkPath(config.TempDirectory)
 	defer func() { _ = os.RemoveAll(tempCorpus) }()
 
 	log.Println("[*] Mirroring corpus")
-	if downloaded, deleted, err := task.cloud.MirrorLocal(cloudCorpusPath, localCorpusPath); err != nil {
+	sharded, err := shardedCorpus(task.cloud, task.config)
+	if err != nil {
+		return fmt.Errorf("failed to list corpus shards: %s", err.Error())
+	}
+	mirror := func() (*cloudutil.TransferResult, error) {
+		return task.cloud.MirrorLocal(cloudCorpusPath, localCorpusPath)
+	}
+	if sharded {
+		mirror = func() (*cloudutil.TransferResult, error) {
+			return task.cloud.MirrorLocalShards(cloudShardPath, localCorpusPath, task.config.FilePath(config.LocalShardIndex))
+		}
+	}
+	// An input missing from the merge would be deleted from the bucket, so only merge a complete corpus
+	mirrored, err := mirror()
+	if err != nil {
 		return errors.New(fmt.Sprintf("corpus mirror failed: %s", err.Error()))
-	} else {
-		log.Printf("[-] Downloaded: %d || Deleted (local): %d", downloaded, deleted)
+	}
+	log.Printf("[-] Downloaded: %d || Deleted (local): %d", len(mirrored.Succeeded), len(mirrored.Deleted))
+	// merged holds the objects that went into the merge, for a sharded corpus only those shards can be replaced
+	merged := make(map[string]bool)
+	for _, key := range append(mirrored.Succeeded, mirrored.Skipped...) {
+		merged[key] = true
 	}
 
 	// Run the actual merge job
 	log.Println("[*] Running merge")
-	var args []string
-	args = append(args, "-merge=1")
-	args = append(args, task.config.Fuzzer.Arguments...)
-	args = append(args, tempCorpus, task.config.WorkPath(config.CorpusDirectory))
-	cmd := exec.CommandContext(task.context, task.config.FilePath(config.LocalFuzzerFile), args...)
+	cmd, err := task.engine.MergeCommand(task.context, engineOptions(task.config), tempCorpus, localCorpusPath)
+	if errors.Is(err, engine.ErrUnsupported) {
+		log.Printf("[!] The %s engine does not support corpus merging", task.engine.Name())
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err = sandboxCommand(task.config, cmd); err != nil {
+		return fmt.Errorf("failed to sandbox merge: %s", err.Error())
+	}
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		log.Println(string(out))
@@ -127,15 +160,25 @@ func (task *CorpusMergeTask) Run() error {
 		for _, obj := range newObjects {
 			newKeys = append(newKeys, obj.Key)
 		}
-		if err := task.cloud.Download(newKeys, tempCorpus); err != nil {
+		if _, err := task.cloud.Download(newKeys, tempCorpus); err != nil {
 			return fmt.Errorf("failed to copy new files into merged corpus: %s", err.Error())
 		}
+		for _, key := range newKeys {
+			merged[key] = true
+		}
 	}
 
-	if uploaded, deleted, err := task.cloud.MirrorRemote(tempCorpus, cloudCorpusPath); err != nil {
+	if task.config.CorpusShards.Enabled {
+		if err = task.writeShards(tempCorpus, startTime, merged); err != nil {
+			return err
+		}
+	} else if result, err := task.cloud.MirrorRemote(tempCorpus, cloudCorpusPath); err != nil {
 		return errors.New(fmt.Sprintf("corpus mirror failed: %s", err.Error()))
 	} else {
-		log.Printf("[-] Uploaded: %d || Deleted (remote): %d", uploaded, deleted)
+		log.Printf("[-] Uploaded: %d || Deleted (remote): %d", len(result.Succeeded), len(result.Deleted))
+		if err = task.writeCorpusManifest(tempCorpus, startTime); err != nil {
+			log.Printf("[!] Failed to write corpus manifest: %s", err.Error())
+		}
 	}
 
 	// Now we are done for real, update the lockfile again just to update the modified time
@@ -0,0 +1,195 @@
+package tasks
+
+import (
+	"FuzzerMan/pkg/config"
+	"FuzzerMan/pkg/engine"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CrashMetadata is uploaded as a JSON sidecar next to each crash artifact (`artifacts/<artifact>.json`), it records
+// the build and invocation that found the crash so it can be reproduced long after the binary has been replaced.
+type CrashMetadata struct {
+	InstanceId    string    `json:"instance_id"`
+	Target        string    `json:"target,omitempty"`
+	Engine        string    `json:"engine"`
+	Artifact      string    `json:"artifact"`
+	Build         string    `json:"build,omitempty"`
+	BinarySHA256  string    `json:"binary_sha256"`
+	BinaryModTime time.Time `json:"binary_mtime"`
+	// Argv is the full command line of each fuzzing process, the first being the primary process
+	Argv        [][]string `json:"argv"`
+	Environment []string   `json:"environment"`
+	LogKey      string     `json:"log_key"`
+	ExitCode    int        `json:"exit_code"`
+	Timestamp   time.Time  `json:"timestamp"`
+	// DuplicateOf is the signature of the already known crash this one was deduplicated against, duplicates are
+	// neither reported nor reproduced
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+}
+
+// crashMetadataPath is the local path of an artifact's sidecar, they are kept out of the artifact directory so they
+// are not mistaken for artifacts
+func crashMetadataPath(cfg *config.Config, artifact string) string {
+	return filepath.Join(cfg.WorkPath(config.MetadataDirectory), artifact+".json")
+}
+
+// writeCrashMetadata writes the sidecar for every crash found by a session, they are uploaded along with the artifacts.
+// argv is the command line of each process before it was sandboxed, env the environment of the primary process.
+// logFilename is the name the session's log is uploaded as.
+func (task *FuzzTask) writeCrashMetadata(argv [][]string, env []string, logFilename string, exitCode int, crashes []engine.Crash) {
+	base := CrashMetadata{
+		InstanceId: task.config.InstanceId,
+		Target:     task.config.Target,
+		Engine:     task.engine.Name(),
+		Build:      localBuild(task.config),
+		LogKey:     path.Join(task.config.CloudPath(config.LogDirectory), logFilename),
+		ExitCode:   exitCode,
+	}
+
+	binary := task.config.FilePath(config.LocalFuzzerFile)
+	if info, err := os.Stat(binary); err == nil {
+		base.BinaryModTime = info.ModTime()
+	}
+	if hash, err := fileSHA256(binary); err != nil {
+		log.Printf("[!] Failed to hash target binary: %s", err.Error())
+	} else {
+		base.BinarySHA256 = hash
+	}
+
+	base.Argv = argv
+	if env == nil {
+		// A nil environment means the target inherited FuzzerMan's
+		env = os.Environ()
+	}
+	base.Environment = redactEnvironment(env)
+
+	for _, crash := range crashes {
+		if task.config.Fuzzer.UploadOnlyCrashes && !strings.HasPrefix(crash.Artifact, "crash-") {
+			continue
+		}
+		metadata := base
+		metadata.Artifact = crash.Artifact
+		metadata.Timestamp = time.Now()
+		if info, err := os.Stat(filepath.Join(task.config.WorkPath(config.ArtifactDirectory), crash.Artifact)); err == nil {
+			metadata.Timestamp = info.ModTime()
+		}
+
+		content, err := json.MarshalIndent(metadata, "", "  ")
+		if err == nil {
+			err = os.WriteFile(crashMetadataPath(task.config, crash.Artifact), content, 0660)
+		}
+		if err != nil {
+			log.Printf("[!] Failed to write crash metadata(%s): %s", crash.Artifact, err.Error())
+		}
+	}
+}
+
+// markDuplicateCrash records in the artifact's sidecar that the crash is a duplicate of signature. The session may
+// already have uploaded the sidecar, so it is uploaded again.
+func (task *FuzzTask) markDuplicateCrash(artifact, signature string) error {
+	fn := crashMetadataPath(task.config, artifact)
+	content, err := os.ReadFile(fn)
+	if os.IsNotExist(err) {
+		// Crashes reported from a log have no sidecar
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var metadata CrashMetadata
+	if err = json.Unmarshal(content, &metadata); err != nil {
+		return err
+	}
+	metadata.DuplicateOf = signature
+	if content, err = json.MarshalIndent(metadata, "", "  "); err != nil {
+		return err
+	}
+	if err = os.WriteFile(fn, content, 0660); err != nil {
+		return err
+	}
+	_, err = task.uploadClient().Upload(task.config.WorkPath(config.MetadataDirectory), []string{filepath.Base(fn)},
+		task.config.CloudPath(config.ArtifactDirectory))
+	return err
+}
+
+// isDuplicateCrash returns true if the artifact's sidecar marks it as a duplicate of an already known crash
+func isDuplicateCrash(cfg *config.Config, artifact string) bool {
+	content, err := os.ReadFile(crashMetadataPath(cfg, artifact))
+	if err != nil {
+		return false
+	}
+	var metadata CrashMetadata
+	return json.Unmarshal(content, &metadata) == nil && metadata.DuplicateOf != ""
+}
+
+// credentialPrefixes and credentialWords match the names of variables that hold credentials
+var (
+	credentialPrefixes = []string{"AWS_", "GOOGLE_", "AZURE_", "GCP_"}
+	credentialWords    = []string{"TOKEN", "SECRET", "KEY", "PASSWORD", "PASSWD", "CREDENTIAL", "AUTH"}
+)
+
+// redactEnvironment hides the values of variables that look like credentials. The host's environment holds the
+// bucket's credentials and the sidecar is uploaded and sent to the ReportingEndpoint.
+func redactEnvironment(env []string) []string {
+	out := make([]string, 0, len(env))
+	for _, variable := range env {
+		name, _, _ := strings.Cut(variable, "=")
+		if isCredentialVariable(name) {
+			variable = name + "=<redacted>"
+		}
+		out = append(out, variable)
+	}
+	return out
+}
+
+func isCredentialVariable(name string) bool {
+	name = strings.ToUpper(name)
+	for _, prefix := range credentialPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	for _, word := range credentialWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// UploadNewCrashMetadata uploads the sidecars written since startTime next to their artifacts
+func (task *FuzzTask) UploadNewCrashMetadata(startTime time.Time) error {
+	localMetadataPath := task.config.WorkPath(config.MetadataDirectory)
+	newMetadata, err := newFilesSince(localMetadataPath, startTime)
+	if err != nil {
+		return err
+	}
+
+	if len(newMetadata) > 0 {
+		_, err = task.uploadClient().Upload(localMetadataPath, newMetadata, task.config.CloudPath(config.ArtifactDirectory))
+		return err
+	}
+	return nil
+}
+
+func fileSHA256(fn string) (string, error) {
+	fp, err := os.Open(fn)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = fp.Close() }()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, fp); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
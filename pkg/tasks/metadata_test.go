@@ -0,0 +1,74 @@
+package tasks
+
+import (
+	"FuzzerMan/pkg/cloudutil"
+	"FuzzerMan/pkg/config"
+	"FuzzerMan/pkg/engine"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteCrashMetadata(t *testing.T) {
+	cfg := &config.Config{InstanceId: "instance-1", WorkDirectory: t.TempDir()}
+	cfg.CloudStorage.Prefix = "campaigns/example"
+	_ = os.WriteFile(cfg.FilePath(config.LocalFuzzerFile), []byte("binary"), 0770)
+	task := &FuzzTask{config: cfg, engine: &engine.LibFuzzer{}}
+
+	cmd := exec.Command(cfg.FilePath(config.LocalFuzzerFile), "-fork=1")
+	cmd.Env = []string{"ASAN_OPTIONS=abort_on_error=1", "AWS_SECRET_ACCESS_KEY=hunter2", "GITHUB_TOKEN=hunter2"}
+	crashes := []engine.Crash{{Artifact: "crash-abc"}}
+	task.writeCrashMetadata([][]string{cmd.Args}, cmd.Env, "2023-01-01-000000.00000.log.txt.gz", 1, crashes)
+
+	content, err := os.ReadFile(crashMetadataPath(cfg, "crash-abc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var metadata CrashMetadata
+	if err = json.Unmarshal(content, &metadata); err != nil {
+		t.Fatal(err)
+	}
+	// sha256("binary")
+	if metadata.BinarySHA256 != "9a3a45d01531a20e89ac6ae10b0b0beb0492acd7216a368aa062d1a5fecaf9cd" {
+		t.Errorf("unexpected binary hash %s", metadata.BinarySHA256)
+	}
+	if metadata.LogKey != "campaigns/example/logs/2023-01-01-000000.00000.log.txt.gz" || metadata.ExitCode != 1 {
+		t.Errorf("unexpected metadata %+v", metadata)
+	}
+	if len(metadata.Argv) != 1 || metadata.Argv[0][1] != "-fork=1" || metadata.Environment[0] != cmd.Env[0] {
+		t.Errorf("unexpected invocation %v %v", metadata.Argv, metadata.Environment)
+	}
+	// Credentials in the environment are not uploaded
+	if metadata.Environment[1] != "AWS_SECRET_ACCESS_KEY=<redacted>" || metadata.Environment[2] != "GITHUB_TOKEN=<redacted>" {
+		t.Errorf("expected credentials to be redacted, got %v", metadata.Environment)
+	}
+}
+
+func TestMarkDuplicateCrash(t *testing.T) {
+	bucket := t.TempDir()
+	cfg := &config.Config{WorkDirectory: t.TempDir()}
+	cfg.CloudStorage.BucketURL = "file://" + bucket
+	cfg.CloudStorage.Prefix = "campaign"
+	task := &FuzzTask{config: cfg, context: context.Background()}
+	task.cloud = cloudutil.NewClient(task.context, cfg.CloudStorage.BucketURL)
+
+	_ = os.WriteFile(crashMetadataPath(cfg, "crash-abc"), []byte(`{"artifact": "crash-abc"}`), 0660)
+	if err := task.markDuplicateCrash("crash-abc", "0123456789abcdef"); err != nil {
+		t.Fatal(err)
+	}
+	if !isDuplicateCrash(cfg, "crash-abc") {
+		t.Errorf("expected the crash to be marked as a duplicate")
+	}
+	content, err := os.ReadFile(filepath.Join(bucket, "campaign", "artifacts", "crash-abc.json"))
+	if err != nil || !strings.Contains(string(content), `"duplicate_of": "0123456789abcdef"`) {
+		t.Errorf("expected the marked sidecar to be uploaded, got %q (%v)", content, err)
+	}
+	// Crashes without a sidecar are left alone
+	if err = task.markDuplicateCrash("crash-def", "0123456789abcdef"); err != nil || isDuplicateCrash(cfg, "crash-def") {
+		t.Errorf("unexpected sidecar for crash-def: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package tasks
+
+import (
+	"FuzzerMan/pkg/config"
+	"FuzzerMan/pkg/engine"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// DefaultMinimizeRuns is the `-runs` value used when Minimize.Runs is not set
+	DefaultMinimizeRuns = 10000
+	// DefaultMinimizeTime is the `-max_total_time` value used when Minimize.MaxTotalTime is not set
+	DefaultMinimizeTime = 300
+)
+
+// MinimizeCrash runs the engine's crash minimization on the artifact, writing the smallest input that still crashes
+// to outputPath. engine.ErrUnsupported is returned for engines that can't minimize a single crash.
+func MinimizeCrash(ctx context.Context, eng engine.Engine, cfg *config.Config, artifactPath, outputPath string) error {
+	opts := engineOptions(cfg)
+	opts.Runs = cfg.Minimize.Runs
+	if opts.Runs <= 0 {
+		opts.Runs = DefaultMinimizeRuns
+	}
+	opts.MaxTotalTime = cfg.Minimize.MaxTotalTime
+	if opts.MaxTotalTime <= 0 {
+		opts.MaxTotalTime = DefaultMinimizeTime
+	}
+
+	cmd, err := eng.MinimizeCommand(ctx, opts, artifactPath, outputPath)
+	if err != nil {
+		return err
+	}
+	log.Printf("[*] Minimizing crash: %s", filepath.Base(artifactPath))
+	if err = sandboxCommand(cfg, cmd); err != nil {
+		return fmt.Errorf("failed to sandbox minimization: %s", err.Error())
+	}
+	out, err := cmd.CombinedOutput()
+
+	if _, statErr := os.Stat(outputPath); statErr != nil {
+		if err != nil {
+			log.Println(string(out))
+			return err
+		}
+		return fmt.Errorf("minimization did not produce an output: %s", statErr.Error())
+	}
+	return nil
+}
+
+// minimizeCrash minimizes a local artifact and uploads the result to the artifacts prefix as `<artifact>.minimized`
+// the local path to the minimized input is returned.
+func (task *FuzzTask) minimizeCrash(artifact string) (string, error) {
+	localMinimizedPath := task.config.WorkPath(config.MinimizedDirectory)
+	minimizedFilename := artifact + ".minimized"
+	outputPath := filepath.Join(localMinimizedPath, minimizedFilename)
+	artifactPath := filepath.Join(task.config.WorkPath(config.ArtifactDirectory), artifact)
+
+	if err := MinimizeCrash(task.context, task.engine, task.config, artifactPath, outputPath); err != nil {
+		return "", err
+	}
+
+	if original, err := os.Stat(artifactPath); err == nil {
+		if minimized, err := os.Stat(outputPath); err == nil {
+			log.Printf("[-] Minimized %s: %d -> %d bytes", artifact, original.Size(), minimized.Size())
+		}
+	}
+
+	cloudArtifactPath := task.config.CloudPath(config.ArtifactDirectory)
+	if _, err := task.uploadClient().Upload(localMinimizedPath, []string{minimizedFilename}, cloudArtifactPath); err != nil {
+		return outputPath, err
+	}
+	return outputPath, nil
+}
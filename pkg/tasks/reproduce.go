@@ -0,0 +1,292 @@
+package tasks
+
+import (
+	"FuzzerMan/pkg/cloudutil"
+	"FuzzerMan/pkg/config"
+	"FuzzerMan/pkg/engine"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"gocloud.dev/blob"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// DefaultReproduceRuns is the number of times an artifact is run when Reproduce.Runs is not set
+const DefaultReproduceRuns = 5
+
+// Verdict is the classification of a crash artifact after reproducing it multiple times
+type Verdict string
+
+const (
+	VerdictReliable        Verdict = "reliable"
+	VerdictFlaky           Verdict = "flaky"
+	VerdictNonReproducible Verdict = "non-reproducible"
+)
+
+type Reproduction struct {
+	Verdict Verdict `json:"verdict"`
+	Runs    int     `json:"runs"`
+	Crashes int     `json:"crashes"`
+}
+
+func (r *Reproduction) String() string {
+	return fmt.Sprintf("%s (%d/%d)", r.Verdict, r.Crashes, r.Runs)
+}
+
+// Metadata is the blob metadata attached to reproduced artifacts
+func (r *Reproduction) Metadata() map[string]string {
+	return map[string]string{
+		"verdict":           string(r.Verdict),
+		"reproduce_crashes": strconv.Itoa(r.Crashes),
+		"reproduce_runs":    strconv.Itoa(r.Runs),
+	}
+}
+
+// saveReproduction keeps the verdict of a crash reproduced while reporting it, so the reproduce task can record it
+// without running the artifact again
+func saveReproduction(cfg *config.Config, artifact string, result *Reproduction) error {
+	content, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(cfg.WorkPath(config.ReproducedDirectory), artifact), content, 0660)
+}
+
+func loadReproduction(cfg *config.Config, artifact string) (*Reproduction, bool) {
+	content, err := os.ReadFile(filepath.Join(cfg.WorkPath(config.ReproducedDirectory), artifact))
+	if err != nil {
+		return nil, false
+	}
+	var result Reproduction
+	if err = json.Unmarshal(content, &result); err != nil || result.Verdict == "" {
+		return nil, false
+	}
+	return &result, true
+}
+
+// ReproduceCrash runs the target binary against the artifact Reproduce.Runs times and classifies the crash based on
+// how many of those runs crashed.
+func ReproduceCrash(ctx context.Context, eng engine.Engine, cfg *config.Config, artifactPath string) (*Reproduction, error) {
+	runs := cfg.Reproduce.Runs
+	if runs <= 0 {
+		runs = DefaultReproduceRuns
+	}
+
+	opts := engineOptions(cfg)
+	out := &Reproduction{Runs: runs}
+	for i := 0; i < runs; i++ {
+		cmd, err := eng.ReproduceCommand(ctx, opts, artifactPath)
+		if err != nil {
+			return nil, err
+		}
+		if err = sandboxCommand(cfg, cmd); err != nil {
+			return nil, fmt.Errorf("failed to sandbox reproduction: %s", err.Error())
+		}
+
+		err = cmd.Run()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			if _, ok := err.(*exec.ExitError); !ok {
+				return nil, err
+			}
+			if sandboxFailed(cfg, err) {
+				return nil, fmt.Errorf("sandbox failed to run the target: %s", err.Error())
+			}
+			out.Crashes++
+		}
+	}
+
+	switch out.Crashes {
+	case out.Runs:
+		out.Verdict = VerdictReliable
+	case 0:
+		out.Verdict = VerdictNonReproducible
+	default:
+		out.Verdict = VerdictFlaky
+	}
+	return out, nil
+}
+
+// captureTimeout limits how long the target may run to capture the output of a crash
+const captureTimeout = 60 * time.Second
+
+// captureCrashOutput runs the target against an artifact, within the sandbox, to get the sanitizer report for
+// engines that do not log the target's output
+func captureCrashOutput(ctx context.Context, eng engine.Engine, cfg *config.Config, artifactPath string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, captureTimeout)
+	defer cancel()
+
+	cmd, err := eng.ReproduceCommand(ctx, engineOptions(cfg), artifactPath)
+	if err != nil {
+		return nil, err
+	}
+	if err = sandboxCommand(cfg, cmd); err != nil {
+		return nil, fmt.Errorf("failed to sandbox reproduction: %s", err.Error())
+	}
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err = cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return nil, err
+		}
+		if sandboxFailed(cfg, err) {
+			return nil, fmt.Errorf("sandbox failed to run the target: %s", bytes.TrimSpace(out.Bytes()))
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// CrashReproduceTask records the verdict of the artifacts this instance has uploaded in the uploaded artifact's
+// metadata. It only sees the instance's own work directory, artifacts uploaded by other instances are left to them.
+// Crashes reported by a fuzzing run were already reproduced while reporting them and their verdict is reused, only
+// artifacts the engine wrote without reporting, or whose report didn't reproduce them, are run against the current
+// target binary here.
+type CrashReproduceTask struct {
+	config  *config.Config
+	cloud   *cloudutil.Client
+	engine  engine.Engine
+	context context.Context
+	// lastRun and pending are persisted in the work directory between tasks. pending are artifacts that were waiting
+	// for their report to reproduce them during the last run.
+	lastRun time.Time
+	pending map[string]bool
+	targets targetTasks[*CrashReproduceTask]
+}
+
+func (task *CrashReproduceTask) Initialize(ctx context.Context, cfg *config.Config) error {
+	var err error
+	task.config = cfg
+	task.context = ctx
+	task.cloud = cloudutil.NewClient(ctx, task.config.CloudStorage.BucketURL)
+	if state, ok := loadReproduceState(cfg); ok {
+		task.lastRun = state.LastRun
+		task.pending = make(map[string]bool)
+		for _, fn := range state.Pending {
+			task.pending[fn] = true
+		}
+	} else if task.lastRun.IsZero() {
+		task.lastRun = time.Now()
+	}
+	task.engine, err = engine.Get(cfg.Fuzzer.Engine)
+	return err
+}
+
+func (task *CrashReproduceTask) Run() error {
+	if targets := localTargets(task.config); len(targets) > 0 {
+		// Targets first seen after startup still need to check the artifacts found since then
+		return task.targets.runAll(task.context, targets, func() *CrashReproduceTask {
+			return &CrashReproduceTask{lastRun: task.lastRun}
+		})
+	}
+	if !task.config.Reproduce.Enabled {
+		return nil
+	}
+	startTime := time.Now()
+	localArtifactPath := task.config.WorkPath(config.ArtifactDirectory)
+	cloudArtifactPath := task.config.CloudPath(config.ArtifactDirectory)
+
+	artifacts, err := newFilesSince(localArtifactPath, task.lastRun)
+	if err != nil {
+		return err
+	}
+	for fn := range task.pending {
+		artifacts = append(artifacts, fn)
+	}
+	pending := make(map[string]bool)
+
+	for _, fn := range artifacts {
+		localFn := filepath.Join(localArtifactPath, fn)
+		if _, err := os.Stat(localFn); err != nil {
+			// Pruned from the work directory
+			continue
+		}
+		if isDuplicateCrash(task.config, fn) {
+			// Dropped while reporting it, the crash it duplicates has already been classified
+			continue
+		}
+		result, ok := loadReproduction(task.config, fn)
+		if !ok && !task.pending[fn] {
+			if _, err := os.Stat(crashMetadataPath(task.config, fn)); err == nil {
+				// The crash is reproduced while it is reported, its verdict is recorded once that has finished. A
+				// report that still hasn't saved a verdict by the next run failed to reproduce it.
+				pending[fn] = true
+				continue
+			}
+		}
+
+		key := path.Join(cloudArtifactPath, fn)
+		attrs, err := task.cloud.FileInfo(key)
+		if err != nil || attrs.Metadata["verdict"] != "" {
+			// Either it was never uploaded or it has already been classified
+			continue
+		}
+
+		if !ok {
+			if result, err = ReproduceCrash(task.context, task.engine, task.config, localFn); err != nil {
+				log.Printf("[!] Failed to reproduce %s: %s", fn, err.Error())
+				continue
+			}
+			log.Printf("[-] Reproduced %s: %s", fn, result)
+		}
+
+		// Metadata can't be updated in place, so the artifact is uploaded again along with the verdict
+		content, err := os.ReadFile(localFn)
+		if err != nil {
+			log.Printf("[!] Failed to read %s: %s", fn, err.Error())
+			continue
+		}
+		if err = task.cloud.WriteFile(key, content, &blob.WriterOptions{Metadata: result.Metadata()}); err != nil {
+			log.Printf("[!] Failed to update artifact metadata(%s): %s", fn, err.Error())
+		}
+	}
+
+	task.lastRun = startTime
+	task.pending = pending
+	if err = saveReproduceState(task.config, task.lastRun, task.pending); err != nil {
+		log.Printf("[!] Failed to save reproduce state: %s", err.Error())
+	}
+	return nil
+}
+
+// reproduceState is the CrashReproduceTask's progress, it is kept in the work directory as MultiFuzzerMan creates new
+// tasks for every time slot, artifacts found late in the previous slot would otherwise never be classified
+type reproduceState struct {
+	LastRun time.Time `json:"last_run"`
+	Pending []string  `json:"pending,omitempty"`
+}
+
+func loadReproduceState(cfg *config.Config) (*reproduceState, bool) {
+	content, err := os.ReadFile(cfg.FilePath(config.LocalReproduceStateFile))
+	if err != nil {
+		return nil, false
+	}
+	var state reproduceState
+	if err = json.Unmarshal(content, &state); err != nil || state.LastRun.IsZero() {
+		return nil, false
+	}
+	return &state, true
+}
+
+func saveReproduceState(cfg *config.Config, lastRun time.Time, pending map[string]bool) error {
+	state := reproduceState{LastRun: lastRun}
+	for fn := range pending {
+		state.Pending = append(state.Pending, fn)
+	}
+	sort.Strings(state.Pending)
+	content, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(cfg.FilePath(config.LocalReproduceStateFile), content, 0660)
+}
@@ -0,0 +1,205 @@
+package tasks
+
+import (
+	"FuzzerMan/pkg/config"
+	"FuzzerMan/pkg/engine"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReproduceCrash(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a shell script as the target binary")
+	}
+
+	tests := []struct {
+		name     string
+		script   string
+		expected Verdict
+		crashes  int
+	}{
+		{"reliable", "#!/bin/sh\nexit 1\n", VerdictReliable, 4},
+		{"non-reproducible", "#!/bin/sh\nexit 0\n", VerdictNonReproducible, 0},
+		// Crashes every other run by toggling a marker file next to the artifact
+		{"flaky", "#!/bin/sh\nif [ -f \"$1.seen\" ]; then rm \"$1.seen\"; exit 0; fi\ntouch \"$1.seen\"\nexit 1\n", VerdictFlaky, 2},
+	}
+
+	for _, test := range tests {
+		cfg := &config.Config{WorkDirectory: t.TempDir()}
+		cfg.Reproduce.Runs = 4
+		if err := os.WriteFile(cfg.FilePath(config.LocalFuzzerFile), []byte(test.script), 0770); err != nil {
+			t.Fatal(err)
+		}
+		artifact := filepath.Join(cfg.WorkPath(config.ArtifactDirectory), "crash-test")
+		if err := os.WriteFile(artifact, []byte("A"), 0660); err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := ReproduceCrash(context.Background(), &engine.LibFuzzer{}, cfg, artifact)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err.Error())
+		}
+		if result.Verdict != test.expected || result.Crashes != test.crashes {
+			t.Errorf("%s: got %s, expected %s (%d/4)", test.name, result, test.expected, test.crashes)
+		}
+	}
+}
+
+func TestReproduceCrashSandboxed(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("sandboxing needs linux namespaces")
+	}
+	if err := exec.Command("unshare", "--net", "--mount", "--map-root-user", "true").Run(); err != nil {
+		t.Skipf("unable to create namespaces: %s", err.Error())
+	}
+
+	cfg := &config.Config{WorkDirectory: t.TempDir()}
+	cfg.Sandbox.Enabled = true
+	cfg.Reproduce.Runs = 2
+	// The target crashes only if it managed to modify the work directory
+	script := "#!/bin/sh\ntouch \"" + filepath.Join(cfg.WorkDirectory, "escaped") + "\" && exit 1\nexit 0\n"
+	if err := os.WriteFile(cfg.FilePath(config.LocalFuzzerFile), []byte(script), 0770); err != nil {
+		t.Fatal(err)
+	}
+	artifact := filepath.Join(cfg.WorkPath(config.ArtifactDirectory), "crash-test")
+	_ = os.WriteFile(artifact, []byte("A"), 0660)
+
+	result, err := ReproduceCrash(context.Background(), &engine.LibFuzzer{}, cfg, artifact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Verdict != VerdictNonReproducible {
+		t.Errorf("expected the target to run sandboxed, got %s", result)
+	}
+}
+
+func TestReproduceCrashSandboxFailure(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("sandboxing needs linux namespaces")
+	}
+	if err := exec.Command("unshare", "--net", "--mount", "--map-root-user", "true").Run(); err != nil {
+		t.Skipf("unable to create namespaces: %s", err.Error())
+	}
+
+	cfg := &config.Config{WorkDirectory: t.TempDir()}
+	cfg.Sandbox.Enabled = true
+	// The sandbox can't bind a writable path that doesn't exist, so the target never runs
+	cfg.Sandbox.WritablePaths = []string{filepath.Join(cfg.WorkDirectory, "missing")}
+	if err := os.WriteFile(cfg.FilePath(config.LocalFuzzerFile), []byte("#!/bin/sh\nexit 0\n"), 0770); err != nil {
+		t.Fatal(err)
+	}
+	artifact := filepath.Join(cfg.WorkPath(config.ArtifactDirectory), "crash-test")
+	_ = os.WriteFile(artifact, []byte("A"), 0660)
+
+	if result, err := ReproduceCrash(context.Background(), &engine.LibFuzzer{}, cfg, artifact); err == nil {
+		t.Errorf("expected the sandbox failure to be an error, got %s", result)
+	}
+}
+
+func TestCrashReproduceTaskReusesReportVerdict(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a shell script as the target binary")
+	}
+	bucket := t.TempDir()
+	cfg := &config.Config{WorkDirectory: t.TempDir()}
+	cfg.CloudStorage.BucketURL = "file://" + bucket
+	cfg.CloudStorage.Prefix = "campaign"
+	cfg.Reproduce.Enabled = true
+	cfg.Reproduce.Runs = 2
+	// Every run of the target is counted
+	runs := filepath.Join(cfg.WorkDirectory, "runs")
+	script := "#!/bin/sh\necho run >> \"" + runs + "\"\nexit 1\n"
+	if err := os.WriteFile(cfg.FilePath(config.LocalFuzzerFile), []byte(script), 0770); err != nil {
+		t.Fatal(err)
+	}
+	artifacts := []string{"crash-reported", "crash-reporting", "crash-dropped", "crash-duplicate", "crash-unreported"}
+	for _, artifact := range artifacts {
+		_ = os.WriteFile(filepath.Join(cfg.WorkPath(config.ArtifactDirectory), artifact), []byte(artifact), 0660)
+		_ = os.MkdirAll(filepath.Join(bucket, "campaign", "artifacts"), 0770)
+		_ = os.WriteFile(filepath.Join(bucket, "campaign", "artifacts", artifact), []byte(artifact), 0660)
+	}
+	// Crashes found by a fuzzing run have a metadata sidecar, the report reproduces them
+	_ = os.WriteFile(crashMetadataPath(cfg, "crash-reported"), []byte("{}"), 0660)
+	_ = os.WriteFile(crashMetadataPath(cfg, "crash-reporting"), []byte("{}"), 0660)
+	_ = os.WriteFile(crashMetadataPath(cfg, "crash-dropped"), []byte("{}"), 0660)
+	// Duplicates are marked as such while reporting them and never run
+	_ = os.WriteFile(crashMetadataPath(cfg, "crash-duplicate"), []byte(`{"duplicate_of": "abc"}`), 0660)
+	if err := saveReproduction(cfg, "crash-reported", &Reproduction{Verdict: VerdictFlaky, Runs: 5, Crashes: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	task := &CrashReproduceTask{lastRun: time.Now().Add(-time.Hour)}
+	if err := task.Initialize(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	verdicts := func() map[string]string {
+		out := make(map[string]string)
+		for _, artifact := range artifacts {
+			attrs, err := task.cloud.FileInfo("campaign/artifacts/" + artifact)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out[artifact] = attrs.Metadata["verdict"]
+		}
+		return out
+	}
+
+	if err := task.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if v := verdicts(); v["crash-reported"] != "flaky" || v["crash-reporting"] != "" || v["crash-dropped"] != "" || v["crash-unreported"] != "reliable" {
+		t.Errorf("unexpected verdicts %v", v)
+	}
+	// Only the unreported artifact was run
+	if content, _ := os.ReadFile(runs); strings.Count(string(content), "run") != 2 {
+		t.Errorf("expected 2 runs of the target, got %q", content)
+	}
+
+	// The crash still being reported gets its verdict once the report has reproduced it. The pending artifacts are
+	// kept in the work directory, like MultiFuzzerMan the next run is made by a new task.
+	_ = saveReproduction(cfg, "crash-reporting", &Reproduction{Verdict: VerdictNonReproducible, Runs: 5})
+	task = &CrashReproduceTask{}
+	if err := task.Initialize(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := task.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if v := verdicts(); v["crash-reporting"] != "non-reproducible" {
+		t.Errorf("unexpected verdicts %v", v)
+	}
+	// A report that didn't save a verdict by the next run is reproduced here instead
+	if v := verdicts(); v["crash-dropped"] != "reliable" || v["crash-duplicate"] != "" {
+		t.Errorf("unexpected verdicts %v", v)
+	}
+	if content, _ := os.ReadFile(runs); strings.Count(string(content), "run") != 4 {
+		t.Errorf("expected only the dropped crash to be run, got %q", content)
+	}
+}
+
+func TestCaptureCrashOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a shell script as the target binary")
+	}
+	cfg := &config.Config{WorkDirectory: t.TempDir()}
+	script := "#!/bin/sh\necho \"==1==ERROR: AddressSanitizer: heap-buffer-overflow\" >&2\nexit 1\n"
+	if err := os.WriteFile(cfg.FilePath(config.LocalFuzzerFile), []byte(script), 0770); err != nil {
+		t.Fatal(err)
+	}
+	artifact := filepath.Join(cfg.WorkPath(config.ArtifactDirectory), "crash-test")
+	_ = os.WriteFile(artifact, []byte("A"), 0660)
+
+	out, err := captureCrashOutput(context.Background(), &engine.AFLPlusPlus{}, cfg, artifact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "heap-buffer-overflow") {
+		t.Errorf("expected the sanitizer report, got %q", out)
+	}
+}
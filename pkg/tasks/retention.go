@@ -0,0 +1,142 @@
+package tasks
+
+import (
+	"FuzzerMan/pkg/config"
+	"compress/gzip"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// compressLog gzips a log in the local log directory, replacing the original. The name of the compressed log is
+// returned.
+func compressLog(dir, name string) (string, error) {
+	compressed := name + ".gz"
+	src, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return name, err
+	}
+	defer func() { _ = src.Close() }()
+
+	dst, err := os.OpenFile(filepath.Join(dir, compressed), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0660)
+	if err != nil {
+		return name, err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err == nil {
+		err = gz.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(filepath.Join(dir, compressed))
+		return name, err
+	}
+
+	_ = src.Close()
+	_ = os.Remove(filepath.Join(dir, name))
+	return compressed, nil
+}
+
+// readLog reads a log, decompressing it if it was compressed by compressLog
+func readLog(fn string) ([]byte, error) {
+	fp, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = fp.Close() }()
+
+	if !strings.HasSuffix(fn, ".gz") {
+		return io.ReadAll(fp)
+	}
+	gz, err := gzip.NewReader(fp)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = gz.Close() }()
+	return io.ReadAll(gz)
+}
+
+type retainedFile struct {
+	path, key string
+	info      os.FileInfo
+	// localOnly is true for files that are never uploaded, they are removed without checking the bucket
+	localOnly bool
+	// artifact is the artifact a file in one of the artifact directories belongs to
+	artifact string
+}
+
+// pruneWorkDirectory applies the retention policy to the local logs and artifacts, along with the artifacts' crash
+// metadata and minimized inputs. Only files that made it to the bucket are removed. Files modified after `before`
+// are kept, as are the files of artifacts whose crash report is still running.
+func (task *FuzzTask) pruneWorkDirectory(before time.Time) {
+	maxAge := time.Duration(task.config.Retention.MaxAgeHours) * time.Hour
+	maxSize := int64(task.config.Retention.MaxSizeMB) * 1024 * 1024
+	if maxAge <= 0 && maxSize <= 0 {
+		return
+	}
+
+	dirs := map[config.DirectoryName]config.DirectoryName{
+		config.LogDirectory:        config.LogDirectory,
+		config.ArtifactDirectory:   config.ArtifactDirectory,
+		config.MetadataDirectory:   config.ArtifactDirectory,
+		config.MinimizedDirectory:  config.ArtifactDirectory,
+		config.ReproducedDirectory: config.ArtifactDirectory,
+	}
+	var files []retainedFile
+	var total int64
+	for local, cloud := range dirs {
+		dir := task.config.WorkPath(local)
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil || entry.IsDir() {
+				continue
+			}
+			total += info.Size()
+			f := retainedFile{
+				path:      filepath.Join(dir, entry.Name()),
+				key:       path.Join(task.config.CloudPath(cloud), entry.Name()),
+				info:      info,
+				localOnly: local == config.ArtifactDirectory && task.config.Fuzzer.UploadOnlyCrashes && !strings.HasPrefix(entry.Name(), "crash-"),
+			}
+			if local != config.LogDirectory {
+				f.artifact = strings.TrimSuffix(strings.TrimSuffix(entry.Name(), ".json"), ".minimized")
+			}
+			files = append(files, f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].info.ModTime().Before(files[j].info.ModTime()) })
+
+	removed := 0
+	for _, f := range files {
+		expired := maxAge > 0 && time.Since(f.info.ModTime()) > maxAge
+		oversized := maxSize > 0 && total > maxSize
+		if !f.info.ModTime().Before(before) || (!expired && !oversized) || task.isReporting(f.artifact) {
+			continue
+		}
+		if !f.localOnly {
+			if _, err := task.cloud.FileInfo(f.key); err != nil {
+				continue
+			}
+		}
+		if err := os.Remove(f.path); err != nil {
+			log.Printf("[!] Failed to prune %s: %s", f.path, err.Error())
+			continue
+		}
+		total -= f.info.Size()
+		removed++
+	}
+	if removed > 0 {
+		log.Printf("[-] Pruned %d old files from the work directory", removed)
+	}
+}
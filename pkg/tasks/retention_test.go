@@ -0,0 +1,94 @@
+package tasks
+
+import (
+	"FuzzerMan/pkg/cloudutil"
+	"FuzzerMan/pkg/config"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCompressLog(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "run.log.txt"), []byte("==1==ERROR: AddressSanitizer\n"), 0660); err != nil {
+		t.Fatal(err)
+	}
+	name, err := compressLog(dir, "run.log.txt")
+	if err != nil || name != "run.log.txt.gz" {
+		t.Fatalf("unexpected compressed log %s: %v", name, err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "run.log.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected the uncompressed log to be removed")
+	}
+	if content, err := readLog(filepath.Join(dir, name)); err != nil || string(content) != "==1==ERROR: AddressSanitizer\n" {
+		t.Errorf("unexpected log content %q: %v", content, err)
+	}
+}
+
+func TestPruneWorkDirectory(t *testing.T) {
+	bucket := t.TempDir()
+	cfg := &config.Config{WorkDirectory: t.TempDir()}
+	cfg.CloudStorage.BucketURL = "file://" + bucket
+	cfg.CloudStorage.Prefix = "campaign"
+	cfg.Retention.MaxAgeHours = 24
+	task := &FuzzTask{config: cfg, context: context.Background()}
+	task.cloud = cloudutil.NewClient(task.context, cfg.CloudStorage.BucketURL)
+
+	write := func(dir config.DirectoryName, name string, age time.Duration, uploaded bool) string {
+		fn := filepath.Join(cfg.WorkPath(dir), name)
+		if err := os.WriteFile(fn, []byte(name), 0660); err != nil {
+			t.Fatal(err)
+		}
+		mtime := time.Now().Add(-age)
+		_ = os.Chtimes(fn, mtime, mtime)
+		if uploaded {
+			remote := filepath.Join(bucket, "campaign", string(dir), name)
+			_ = os.MkdirAll(filepath.Dir(remote), 0770)
+			_ = os.WriteFile(remote, []byte(name), 0660)
+		}
+		return fn
+	}
+	oldLog := write(config.LogDirectory, "old.log.txt.gz", 48*time.Hour, true)
+	pending := write(config.ArtifactDirectory, "crash-pending", 48*time.Hour, false)
+	recent := write(config.ArtifactDirectory, "crash-recent", time.Hour, true)
+	large := write(config.ArtifactDirectory, "crash-large", 2*time.Hour, true)
+	if err := os.WriteFile(large, make([]byte, 2*1024*1024), 0660); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Now().Add(-2 * time.Hour)
+	_ = os.Chtimes(large, mtime, mtime)
+	// The crash report of an earlier session is still running
+	reporting := write(config.ArtifactDirectory, "crash-reporting", 48*time.Hour, true)
+	reportingMetadata := write(config.MetadataDirectory, "crash-reporting.json", 48*time.Hour, false)
+	_ = os.WriteFile(filepath.Join(bucket, "campaign", "artifacts", "crash-reporting.json"), []byte("{}"), 0660)
+	task.reportingArtifacts = map[string]bool{"crash-reporting": true}
+
+	task.pruneWorkDirectory(time.Now())
+	expectExists := func(fn string, exists bool) {
+		t.Helper()
+		if _, err := os.Stat(fn); (err == nil) != exists {
+			t.Errorf("%s: expected exists=%v", filepath.Base(fn), exists)
+		}
+	}
+	expectExists(oldLog, false)
+	expectExists(pending, true)
+	expectExists(recent, true)
+	expectExists(large, true)
+
+	// Over the size limit the oldest uploaded files go first
+	cfg.Retention.MaxSizeMB = 1
+	task.pruneWorkDirectory(time.Now())
+	expectExists(large, false)
+	expectExists(recent, true)
+	expectExists(pending, true)
+	expectExists(reporting, true)
+	expectExists(reportingMetadata, true)
+
+	// Once the report has finished the files can go
+	task.reportingArtifacts = nil
+	task.pruneWorkDirectory(time.Now())
+	expectExists(reporting, false)
+	expectExists(reportingMetadata, false)
+}
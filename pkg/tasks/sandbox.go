@@ -0,0 +1,130 @@
+package tasks
+
+import (
+	"FuzzerMan/pkg/config"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// sandboxFailedExitCode is the exit code of the sandbox when it fails to set up, before the target is exec'd. It is
+// the same as the cgroup wrapper's so a failure to start the target isn't mistaken for a crash.
+const sandboxFailedExitCode = 125
+
+// sandboxLauncher checks the namespaces can be created before starting the sandbox, unshare itself exits with 1 when
+// they can't, which is indistinguishable from the target's exit code. Its arguments are the path to unshare and the
+// sandboxScript followed by the script's arguments.
+const sandboxLauncher = `unshare=$1
+script=$2
+shift 2
+"$unshare" --net --mount --map-root-user true || { echo "[!] Failed to create the sandbox namespaces" >&2; exit 125; }
+exec "$unshare" --net --mount --map-root-user -- /bin/sh -c "$script" sandbox "$@"
+`
+
+// sandboxScript runs inside the new namespaces before the target is exec'd, exec keeps the pid the same so signals
+// reach the target (or engine) directly. Its arguments are whether to mount a tmpfs on /tmp, the number of writable
+// directories followed by the directories, and then the command to run.
+const sandboxScript = `fail() { echo "[!] Failed to set up the sandbox: $1" >&2; exit 125; }
+tmpfs=$1
+n=$2
+shift 2
+writable=""
+if [ "$tmpfs" = "tmpfs" ]; then
+	mount -t tmpfs tmpfs /tmp || fail "unable to mount /tmp"
+	writable="/tmp"
+fi
+while [ "$n" -gt 0 ]; do
+	mount --bind "$1" "$1" || fail "unable to bind $1"
+	writable="$writable
+$1"
+	shift
+	n=$((n-1))
+done
+for mnt in $(awk '{print $2}' /proc/self/mounts); do
+	case "$mnt" in
+		/proc|/proc/*|/dev|/dev/*|/sys|/sys/*) continue ;;
+	esac
+	if printf '%s\n' "$writable" | grep -qxF "$mnt"; then
+		continue
+	fi
+	mount -o remount,bind,ro "$mnt" 2>/dev/null || true
+done
+mount -o remount,bind,ro / || fail "unable to remount / read-only"
+cd "$(pwd -P)" || fail "unable to enter $(pwd)"
+exec "$@"
+`
+
+// sandboxPaths are the directories the target may write to, everything else is read-only within the sandbox
+func sandboxPaths(cfg *config.Config) ([]string, error) {
+	var paths []string
+	dirs := []config.DirectoryName{config.CorpusDirectory, config.ArtifactDirectory, config.EngineDirectory, config.TempDirectory,
+		config.MinimizedDirectory}
+	for _, dir := range dirs {
+		paths = append(paths, cfg.WorkPath(dir))
+	}
+	paths = append(paths, cfg.Sandbox.WritablePaths...)
+
+	for i, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		paths[i] = abs
+	}
+	return paths, nil
+}
+
+// sandboxCommand wraps cmd so it runs in its own network and mount namespaces when Sandbox.Enabled is set. The
+// target has no network access and can only write to its work directories and a private /tmp.
+func sandboxCommand(cfg *config.Config, cmd *exec.Cmd) error {
+	if !cfg.Sandbox.Enabled {
+		return nil
+	}
+	unshare, err := exec.LookPath("unshare")
+	if err != nil {
+		return err
+	}
+	paths, err := sandboxPaths(cfg)
+	if err != nil {
+		return err
+	}
+
+	// A tmpfs on /tmp would hide any of the writable directories within it
+	tmpfs := "tmpfs"
+	for _, path := range paths {
+		if path == "/tmp" || strings.HasPrefix(path, "/tmp/") {
+			tmpfs = "none"
+		}
+	}
+
+	// The shell resolves the command from cmd.Dir rather than the current directory
+	binary, err := filepath.Abs(cmd.Path)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"/bin/sh", "-c", sandboxLauncher, "sandbox", unshare, sandboxScript}
+	args = append(args, tmpfs, strconv.Itoa(len(paths)))
+	args = append(args, paths...)
+	args = append(args, binary)
+	args = append(args, cmd.Args[1:]...)
+	cmd.Path = "/bin/sh"
+	cmd.Args = args
+	return nil
+}
+
+// sandboxFailed returns true if err is from a sandboxed command whose sandbox failed to set up or to exec the target,
+// rather than from the target itself. The shell exits with 126 or 127 when the target can't be exec'd.
+func sandboxFailed(cfg *config.Config, err error) bool {
+	var exitErr *exec.ExitError
+	if !cfg.Sandbox.Enabled || !errors.As(err, &exitErr) {
+		return false
+	}
+	switch exitErr.ExitCode() {
+	case sandboxFailedExitCode, 126, 127:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package tasks
+
+import (
+	"FuzzerMan/pkg/config"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSandboxCommand(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("sandboxing needs linux namespaces")
+	}
+	if err := exec.Command("unshare", "--net", "--mount", "--map-root-user", "true").Run(); err != nil {
+		t.Skipf("unable to create namespaces: %s", err.Error())
+	}
+
+	cfg := &config.Config{WorkDirectory: t.TempDir()}
+	cfg.Sandbox.Enabled = true
+	corpus := cfg.WorkPath(config.CorpusDirectory)
+
+	// Only loopback is in the network namespace, and only the work directories are writable
+	script := `touch "$1/input" && ! touch "$2/fuzzer" 2>/dev/null && [ "$(grep -c : /proc/net/dev)" -eq 1 ]`
+	cmd := exec.Command("sh", "-c", script, "sh", corpus, cfg.WorkDirectory)
+	cmd.Dir = cfg.WorkPath(config.EngineDirectory)
+	if err := sandboxCommand(cfg, cmd); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("sandboxed command failed: %s\n%s", err.Error(), out)
+	}
+
+	if _, err := os.Stat(filepath.Join(corpus, "input")); err != nil {
+		t.Errorf("expected the corpus to be writable: %s", err.Error())
+	}
+	if _, err := os.Stat(filepath.Join(cfg.WorkDirectory, "fuzzer")); err == nil {
+		t.Errorf("expected the work directory to be read-only")
+	}
+}
+
+func TestSandboxCommandRelativeBinary(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("sandboxing needs linux namespaces")
+	}
+	if err := exec.Command("unshare", "--net", "--mount", "--map-root-user", "true").Run(); err != nil {
+		t.Skipf("unable to create namespaces: %s", err.Error())
+	}
+
+	// A relative work directory gives a relative binary, which must still be found from the command's own directory
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := filepath.Rel(wd, t.TempDir())
+	if err != nil {
+		t.Skip(err)
+	}
+	cfg := &config.Config{WorkDirectory: dir}
+	cfg.Sandbox.Enabled = true
+	if err = os.WriteFile(cfg.FilePath(config.LocalFuzzerFile), []byte("#!/bin/sh\nexit 0\n"), 0770); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(cfg.FilePath(config.LocalFuzzerFile))
+	cmd.Dir = filepath.Join(cfg.WorkPath(config.EngineDirectory), "go", "workspace")
+	_ = os.MkdirAll(cmd.Dir, 0770)
+	if err = sandboxCommand(cfg, cmd); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("sandboxed command failed: %s\n%s", err.Error(), out)
+	}
+}
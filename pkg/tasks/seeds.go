@@ -0,0 +1,159 @@
+package tasks
+
+import (
+	"FuzzerMan/pkg/config"
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// bootstrapCorpus fills an empty corpus from the campaign's `seeds/` prefix and uploads it as the initial corpus.
+// Seeds can be loose files or zip, tar and tar.gz archives, every file in an archive is used as a seed.
+func (task *FuzzTask) bootstrapCorpus() error {
+	objects, err := task.cloud.NewObjects(task.config.CloudPath(config.SeedDirectory)+"/", time.Time{})
+	if err != nil || len(objects) == 0 {
+		return err
+	}
+
+	log.Printf("[*] Corpus is empty, bootstrapping from %d seed files", len(objects))
+	localSeedPath := task.config.WorkPath(config.SeedDirectory)
+	defer func() { _ = os.RemoveAll(localSeedPath) }()
+
+	var keys []string
+	for _, obj := range objects {
+		keys = append(keys, obj.Key)
+	}
+	// Seeds in different folders can share a base name, a failed seed is skipped rather than failing the bootstrap
+	result, err := task.cloud.DownloadAs(keys, localSeedPath, seedFileName)
+	if len(result.Succeeded) == 0 {
+		return err
+	}
+	if err != nil {
+		log.Printf("[!] Failed to download %d of %d seed files", len(result.Failed), len(keys))
+	}
+
+	localCorpusPath := task.config.WorkPath(config.CorpusDirectory)
+	var seeds []string
+	seen := make(map[string]bool)
+	for _, key := range result.Succeeded {
+		names, err := extractSeeds(filepath.Join(localSeedPath, seedFileName(key)), localCorpusPath)
+		if err != nil {
+			log.Printf("[!] Failed to extract seeds from %s: %s", key, err.Error())
+		}
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				seeds = append(seeds, name)
+			}
+		}
+	}
+
+	log.Printf("[-] Seeds: %d", len(seeds))
+	if len(seeds) == 0 {
+		return nil
+	}
+	return uploadCorpus(task.uploadClient(), task.config, seeds)
+}
+
+// seedFileName names the local copy of a seed after the SHA1 of its key, keeping the base name so archives are still
+// recognised by their extension
+func seedFileName(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:]) + "-" + path.Base(key)
+}
+
+// extractSeeds writes every seed in the file into the corpus directory, an archive's files or the file itself. The
+// seeds are named by the SHA1 of their content like libFuzzer's corpus so instances bootstrapping at the same time
+// upload the same files.
+func extractSeeds(fn, corpusDir string) ([]string, error) {
+	var out []string
+	fp, err := os.Open(fn)
+	if err != nil {
+		return out, err
+	}
+	defer func() { _ = fp.Close() }()
+
+	name := strings.ToLower(filepath.Base(fn))
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		info, err := fp.Stat()
+		if err != nil {
+			return out, err
+		}
+		archive, err := zip.NewReader(fp, info.Size())
+		if err != nil {
+			return out, err
+		}
+		for _, f := range archive.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			reader, err := f.Open()
+			if err != nil {
+				return out, err
+			}
+			seed, err := writeSeed(reader, corpusDir)
+			_ = reader.Close()
+			if err != nil {
+				return out, err
+			}
+			out = append(out, seed)
+		}
+		return out, nil
+
+	case strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz") || strings.HasSuffix(name, ".tar"):
+		var reader io.Reader = fp
+		if !strings.HasSuffix(name, ".tar") {
+			gz, err := gzip.NewReader(fp)
+			if err != nil {
+				return out, err
+			}
+			defer func() { _ = gz.Close() }()
+			reader = gz
+		}
+		archive := tar.NewReader(reader)
+		for {
+			header, err := archive.Next()
+			if err == io.EOF {
+				return out, nil
+			} else if err != nil {
+				return out, err
+			}
+			if header.Typeflag != tar.TypeReg {
+				continue
+			}
+			seed, err := writeSeed(archive, corpusDir)
+			if err != nil {
+				return out, err
+			}
+			out = append(out, seed)
+		}
+
+	default:
+		seed, err := writeSeed(fp, corpusDir)
+		if err != nil {
+			return out, err
+		}
+		return append(out, seed), nil
+	}
+}
+
+// writeSeed writes the content of reader into the corpus directory, returning the seed's name
+func writeSeed(reader io.Reader, corpusDir string) (string, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum(content)
+	name := hex.EncodeToString(sum[:])
+	return name, os.WriteFile(filepath.Join(corpusDir, name), content, 0660)
+}
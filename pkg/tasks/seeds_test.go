@@ -0,0 +1,96 @@
+package tasks
+
+import (
+	"FuzzerMan/pkg/cloudutil"
+	"FuzzerMan/pkg/config"
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExtractSeeds(t *testing.T) {
+	root := t.TempDir()
+	corpus := filepath.Join(root, "corpus")
+	_ = os.MkdirAll(corpus, 0770)
+
+	zipFn := filepath.Join(root, "seeds.zip")
+	fp, _ := os.Create(zipFn)
+	zw := zip.NewWriter(fp)
+	for _, content := range []string{"zip-a", "zip-b"} {
+		w, _ := zw.Create("inputs/" + content)
+		_, _ = w.Write([]byte(content))
+	}
+	_ = zw.Close()
+	_ = fp.Close()
+
+	tarFn := filepath.Join(root, "seeds.tar.gz")
+	fp, _ = os.Create(tarFn)
+	gz := gzip.NewWriter(fp)
+	tw := tar.NewWriter(gz)
+	_ = tw.WriteHeader(&tar.Header{Name: "inputs/", Typeflag: tar.TypeDir, Mode: 0770})
+	_ = tw.WriteHeader(&tar.Header{Name: "inputs/tar-a", Typeflag: tar.TypeReg, Mode: 0660, Size: 5})
+	_, _ = tw.Write([]byte("tar-a"))
+	_ = tw.Close()
+	_ = gz.Close()
+	_ = fp.Close()
+
+	looseFn := filepath.Join(root, "loose")
+	_ = os.WriteFile(looseFn, []byte("zip-a"), 0660)
+
+	expected := map[string]int{zipFn: 2, tarFn: 1, looseFn: 1}
+	for fn, count := range expected {
+		seeds, err := extractSeeds(fn, corpus)
+		if err != nil {
+			t.Fatalf("%s: %s", filepath.Base(fn), err.Error())
+		}
+		if len(seeds) != count {
+			t.Errorf("%s: expected %d seeds, got %d", filepath.Base(fn), count, len(seeds))
+		}
+	}
+
+	// The loose file has the same content as a zip entry, so it's the same corpus file
+	files, _ := os.ReadDir(corpus)
+	if len(files) != 3 {
+		t.Errorf("expected 3 corpus files, got %d", len(files))
+	}
+}
+
+func TestBootstrapCorpus(t *testing.T) {
+	bucket := t.TempDir()
+	cfg := &config.Config{WorkDirectory: t.TempDir()}
+	cfg.CloudStorage.BucketURL = "file://" + bucket
+	cfg.CloudStorage.Prefix = "campaign"
+	task := &FuzzTask{config: cfg, context: context.Background()}
+	task.cloud = cloudutil.NewClient(task.context, cfg.CloudStorage.BucketURL)
+
+	for _, key := range []string{"a/seed", "b/seed", "c/seed"} {
+		remote := filepath.Join(bucket, "campaign", "seeds", key)
+		_ = os.MkdirAll(filepath.Dir(remote), 0770)
+		if err := os.WriteFile(remote, []byte(key), 0660); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A directory in place of the local copy makes the download of c/seed fail
+	if err := os.MkdirAll(filepath.Join(cfg.WorkPath(config.SeedDirectory), seedFileName("campaign/seeds/c/seed"), "blocked"), 0770); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := task.bootstrapCorpus(); err != nil {
+		t.Fatal(err)
+	}
+	var uploaded []string
+	entries, _ := os.ReadDir(filepath.Join(bucket, "campaign", "corpus"))
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".attrs") {
+			uploaded = append(uploaded, entry.Name())
+		}
+	}
+	if len(uploaded) != 2 {
+		t.Errorf("expected the 2 downloaded seeds in the corpus, got %v", uploaded)
+	}
+}
@@ -0,0 +1,94 @@
+package tasks
+
+import (
+	"FuzzerMan/pkg/engine"
+	"bytes"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// FuzzStats is the machine-readable record of a single fuzzer run, it is uploaded next to the run's log
+type FuzzStats struct {
+	InstanceId string    `json:"instance_id"`
+	Build      string    `json:"build,omitempty"`
+	LogFile    string    `json:"log_file"`
+	StartTime  time.Time `json:"start_time"`
+	EndTime    time.Time `json:"end_time"`
+	ExitCode   int       `json:"exit_code"`
+	// CgroupOOMKills is the number of processes killed by the cgroup's memory limit during the run
+	CgroupOOMKills int64                `json:"cgroup_oom_kills,omitempty"`
+	Samples        []engine.StatsSample `json:"samples"`
+	Final          engine.StatsSample   `json:"final"`
+}
+
+// StatsCollector is an io.Writer that parses the engine's output line by line as it is written
+type StatsCollector struct {
+	Stats   FuzzStats
+	engine  engine.Engine
+	mu      sync.Mutex
+	partial []byte
+}
+
+func NewStatsCollector(eng engine.Engine, instanceId, logFilename string, start time.Time) *StatsCollector {
+	return &StatsCollector{
+		engine: eng,
+		Stats: FuzzStats{
+			InstanceId: instanceId,
+			LogFile:    logFilename,
+			StartTime:  start,
+		},
+	}
+}
+
+func (c *StatsCollector) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.partial = append(c.partial, p...)
+	for {
+		idx := bytes.IndexByte(c.partial, '\n')
+		if idx < 0 {
+			break
+		}
+		line := string(c.partial[:idx])
+		c.partial = c.partial[idx+1:]
+		c.addLine(line)
+	}
+	return len(p), nil
+}
+
+func (c *StatsCollector) addLine(line string) {
+	sample, ok := c.engine.ParseStats(line)
+	if !ok {
+		return
+	}
+	sample.Elapsed = int(time.Now().Sub(c.Stats.StartTime).Seconds())
+	c.Stats.Samples = append(c.Stats.Samples, sample)
+	c.Stats.Final = sample
+}
+
+// Finish records the end of the run, any unterminated output is parsed as a final line
+func (c *StatsCollector) Finish(exitCode int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.partial) > 0 {
+		c.addLine(string(c.partial))
+		c.partial = nil
+	}
+	c.Stats.EndTime = time.Now()
+	c.Stats.ExitCode = exitCode
+}
+
+func (c *StatsCollector) WriteFile(fn string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	content, err := json.MarshalIndent(c.Stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fn, content, 0660)
+}
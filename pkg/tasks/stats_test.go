@@ -0,0 +1,24 @@
+package tasks
+
+import (
+	"FuzzerMan/pkg/engine"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsCollector(t *testing.T) {
+	collector := NewStatsCollector(&engine.LibFuzzer{}, "test", "test.log.txt", time.Now())
+	output := "INFO: Seed: 1\n#10: cov: 1 ft: 2 corp: 3 exec/s: 4 oom/timeout/crash: 0/0/0 time: 1s\n#20: cov: 5 ft: 6 corp: 7 exec/s: 8 oom/timeout/crash: 0/0/1 time: 2s"
+	for _, chunk := range strings.SplitAfter(output, "c") {
+		_, _ = collector.Write([]byte(chunk))
+	}
+	collector.Finish(0)
+
+	if len(collector.Stats.Samples) != 2 {
+		t.Fatalf("expected 2 samples, got %d", len(collector.Stats.Samples))
+	}
+	if collector.Stats.Final.Runs != 20 || collector.Stats.Final.Crashes != 1 {
+		t.Errorf("unexpected final sample: %+v", collector.Stats.Final)
+	}
+}
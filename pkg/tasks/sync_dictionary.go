@@ -0,0 +1,133 @@
+package tasks
+
+import (
+	"FuzzerMan/pkg/cloudutil"
+	"FuzzerMan/pkg/config"
+	"context"
+	"errors"
+	"fmt"
+	"gocloud.dev/gcerrors"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// SyncDictionaryTask keeps the campaign's dictionaries in sync with the bucket. A campaign can have a single `dict`
+// file next to the `fuzzer` binary, and/or any number of dictionaries under the `dicts/` prefix.
+type SyncDictionaryTask struct {
+	config  *config.Config
+	cloud   *cloudutil.Client
+	context context.Context
+}
+
+func (task *SyncDictionaryTask) Initialize(ctx context.Context, cfg *config.Config) error {
+	task.config = cfg
+	task.context = ctx
+	task.cloud = cloudutil.NewClient(task.context, cfg.CloudStorage.BucketURL)
+	return nil
+}
+
+func (task *SyncDictionaryTask) Run() error {
+	targets := localTargets(task.config)
+	if len(targets) == 0 {
+		return task.syncDictionaries(task.config)
+	}
+	// Each target has its own dictionaries under `targets/<name>/`. A target that fails to sync doesn't stop the
+	// others, the first error is returned.
+	var firstErr error
+	for _, target := range targets {
+		if err := task.syncDictionaries(target); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("target %s: %s", target.Target, err.Error())
+		}
+	}
+	return firstErr
+}
+
+func (task *SyncDictionaryTask) syncDictionaries(cfg *config.Config) error {
+	localpath := cfg.FilePath(config.LocalDictionaryFile)
+	remotepath := cfg.FilePath(config.CloudDictionaryFile)
+
+	object, err := task.cloud.FileInfo(remotepath)
+	if err != nil && gcerrors.Code(err) == gcerrors.NotFound {
+		if err = os.Remove(localpath); err == nil {
+			log.Printf("[*] Removed dictionary: %s", filepath.Base(localpath))
+		}
+	} else if err != nil {
+		return errors.New("failed to get remote modification time for dictionary: " + err.Error())
+	} else if err = task.syncFile(remotepath, object.ModTime, localpath); err != nil {
+		return err
+	}
+
+	// The dicts/ prefix is mirrored, dictionaries removed from the bucket are removed locally as well
+	localDir := cfg.WorkPath(config.DictionaryDirectory)
+	objects, err := task.cloud.NewObjects(cfg.CloudPath(config.DictionaryDirectory)+"/", time.Time{})
+	if err != nil {
+		return errors.New("failed to list dictionaries: " + err.Error())
+	}
+	remoteFiles := make(map[string]bool)
+	for _, obj := range objects {
+		name := path.Base(obj.Key)
+		remoteFiles[name] = true
+		if err = task.syncFile(obj.Key, obj.ModTime, filepath.Join(localDir, name)); err != nil {
+			return err
+		}
+	}
+
+	files, err := os.ReadDir(localDir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if !f.IsDir() && !remoteFiles[f.Name()] {
+			_ = os.Remove(filepath.Join(localDir, f.Name()))
+			log.Printf("[*] Removed dictionary: %s", f.Name())
+		}
+	}
+	return nil
+}
+
+// syncFile downloads the remote dictionary if it is newer than the local copy
+func (task *SyncDictionaryTask) syncFile(remotepath string, remotets time.Time, localpath string) error {
+	if info, err := os.Stat(localpath); err == nil && !remotets.After(info.ModTime()) {
+		return nil
+	} else if err != nil && !os.IsNotExist(err) {
+		return errors.New("failed to stat dictionary: " + err.Error())
+	}
+
+	if err := task.cloud.DownloadSingle(remotepath, localpath); err != nil {
+		return errors.New("failed to fetch dictionary: " + err.Error())
+	}
+	log.Printf("[*] Updated dictionary: %s", filepath.Base(localpath))
+	return nil
+}
+
+// dictionaries returns the synced dictionaries, the `dict` file first followed by the `dicts/` prefix in name order
+func dictionaries(cfg *config.Config) []string {
+	var out []string
+	if _, err := os.Stat(cfg.FilePath(config.LocalDictionaryFile)); err == nil {
+		out = append(out, cfg.FilePath(config.LocalDictionaryFile))
+	}
+
+	localDir := cfg.WorkPath(config.DictionaryDirectory)
+	files, err := os.ReadDir(localDir)
+	if err != nil {
+		return out
+	}
+	for _, f := range files {
+		if !f.IsDir() {
+			out = append(out, filepath.Join(localDir, f.Name()))
+		}
+	}
+	return out
+}
+
+// nextDictionary picks the dictionary for the next fuzzing session, rotating through them one session at a time
+func (task *FuzzTask) nextDictionary() string {
+	dicts := dictionaries(task.config)
+	if len(dicts) == 0 {
+		return ""
+	}
+	return dicts[nextTurn(task.config.FilePath(config.LocalDictionaryTurnFile))%len(dicts)]
+}
@@ -0,0 +1,60 @@
+package tasks
+
+import (
+	"FuzzerMan/pkg/config"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNextDictionary(t *testing.T) {
+	cfg := &config.Config{WorkDirectory: t.TempDir()}
+	task := &FuzzTask{config: cfg}
+	if dict := task.nextDictionary(); dict != "" {
+		t.Errorf("expected no dictionary, got %s", dict)
+	}
+
+	_ = os.WriteFile(cfg.FilePath(config.LocalDictionaryFile), []byte("\"a\""), 0660)
+	for _, name := range []string{"b.dict", "a.dict"} {
+		_ = os.WriteFile(filepath.Join(cfg.WorkPath(config.DictionaryDirectory), name), []byte("\"b\""), 0660)
+	}
+
+	expected := []string{"dict", "a.dict", "b.dict", "dict"}
+	for _, name := range expected {
+		// The rotation carries on across tasks, MultiFuzzerMan creates a new one for every time slot
+		task = &FuzzTask{config: cfg}
+		if dict := task.nextDictionary(); filepath.Base(dict) != name {
+			t.Errorf("expected %s, got %s", name, dict)
+		}
+	}
+}
+
+func TestSyncDictionariesPastFailure(t *testing.T) {
+	bucket := t.TempDir()
+	cfg := &config.Config{WorkDirectory: t.TempDir()}
+	cfg.CloudStorage.BucketURL = "file://" + bucket
+	cfg.CloudStorage.Prefix = "campaign"
+	for _, name := range []string{"a", "b"} {
+		target := cfg.ForTarget(name)
+		_ = os.MkdirAll(target.WorkDirectory, 0770)
+		_ = os.WriteFile(target.FilePath(config.LocalFuzzerFile), []byte{}, 0770)
+		remote := filepath.Join(bucket, "campaign", "targets", name, "dict")
+		_ = os.MkdirAll(filepath.Dir(remote), 0770)
+		_ = os.WriteFile(remote, []byte("\""+name+"\""), 0660)
+	}
+	// The first target's dictionary can't be written, the target after it is still synced
+	blocked := cfg.ForTarget("a").FilePath(config.LocalDictionaryFile)
+	_ = os.MkdirAll(filepath.Join(blocked, "blocked"), 0770)
+	_ = os.Chtimes(blocked, time.Time{}.Add(time.Hour), time.Time{}.Add(time.Hour))
+
+	task := &SyncDictionaryTask{}
+	_ = task.Initialize(context.Background(), cfg)
+	if err := task.Run(); err == nil {
+		t.Errorf("expected target a to fail")
+	}
+	if content, _ := os.ReadFile(cfg.ForTarget("b").FilePath(config.LocalDictionaryFile)); string(content) != "\"b\"" {
+		t.Errorf("expected target b's dictionary to be synced, got %q", content)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"FuzzerMan/pkg/config"
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"os"
 	"time"
@@ -20,8 +21,20 @@ func (task *SyncTargetBinaryTask) Initialize(ctx context.Context, cfg *config.Co
 	task.config = cfg
 	task.context = ctx
 	task.cloud = cloudutil.NewClient(task.context, cfg.CloudStorage.BucketURL)
+	if _, err := parseTrustedKeys(cfg.TrustedKeys); err != nil {
+		return err
+	}
 
-	// Check that the fuzzer exists on the cloud
+	// Check that the fuzzer, or at least one target, exists on the cloud
+	if targets, err := remoteTargets(task.cloud, task.config); err == nil && len(targets) > 0 {
+		return nil
+	}
+	if build, err := task.remoteBuild(task.config); err == nil && build != "" {
+		return nil
+	}
+	if _, attrs, err := task.remoteBundle(task.config); err == nil && attrs != nil {
+		return nil
+	}
 	if _, err := task.cloud.FileInfo(task.config.FilePath(config.CloudFuzzerFile)); err != nil {
 		return err
 	}
@@ -29,12 +42,54 @@ func (task *SyncTargetBinaryTask) Initialize(ctx context.Context, cfg *config.Co
 }
 
 func (task *SyncTargetBinaryTask) Run() error {
-	localpath := task.config.FilePath(config.LocalFuzzerFile)
-	remotepath := task.config.FilePath(config.CloudFuzzerFile)
+	targets, err := remoteTargets(task.cloud, task.config)
+	if err != nil {
+		return errors.New("failed to list targets: " + err.Error())
+	}
+	if len(targets) == 0 {
+		return task.syncBinary(task.config)
+	}
+
+	// A target that fails to sync doesn't stop the others from being updated, the first error is returned
+	var firstErr error
+	remote := make(map[string]bool)
+	for _, name := range targets {
+		remote[name] = true
+		if err = task.syncBinary(task.config.ForTarget(name)); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("target %s: %s", name, err.Error())
+		}
+	}
+
+	// Targets removed from the bucket stop being fuzzed, their corpus and artifacts are kept
+	for _, target := range localTargets(task.config) {
+		if !remote[target.Target] {
+			log.Printf("[*] Removing target binary: %s", target.Target)
+			_ = os.Remove(target.FilePath(config.LocalFuzzerFile))
+		}
+	}
+	return firstErr
+}
+
+func (task *SyncTargetBinaryTask) syncBinary(cfg *config.Config) error {
+	if build, err := task.remoteBuild(cfg); err != nil {
+		return errors.New("failed to get current build: " + err.Error())
+	} else if build != "" {
+		return task.syncBuild(cfg, build)
+	}
+	if key, attrs, err := task.remoteBundle(cfg); err != nil {
+		return errors.New("failed to get remote modification time for target bundle: " + err.Error())
+	} else if attrs != nil {
+		return task.syncBundle(cfg, key, attrs)
+	}
+
+	localpath := cfg.FilePath(config.LocalFuzzerFile)
+	remotepath := cfg.FilePath(config.CloudFuzzerFile)
 
 	var localts, remotets time.Time
 	info, err := os.Stat(localpath)
-	if err == nil {
+	if _, bundled := bundleDirectory(cfg); bundled {
+		// The bundle was removed, the binary is always fetched again
+	} else if err == nil {
 		localts = info.ModTime()
 	} else {
 		if !os.IsNotExist(err) {
@@ -54,14 +109,64 @@ func (task *SyncTargetBinaryTask) Run() error {
 	}
 
 	log.Printf("[*] Fetching updated target binary.")
-	if err = task.cloud.DownloadSingle(remotepath, localpath); err != nil {
+	if err = os.MkdirAll(cfg.WorkDirectory, 0770); err != nil {
+		return errors.New("failed to create target directory: " + err.Error())
+	}
+	download := localpath + ".download"
+	defer func() { _ = os.Remove(download) }()
+	if err = task.cloud.DownloadSingle(remotepath, download); err != nil {
 		return errors.New("failed to fetch target binary: " + err.Error())
 	}
+	if err = task.verifyBinary(cfg, download, remotepath); err != nil {
+		return fmt.Errorf("refusing target binary, keeping the current one: %s", err.Error())
+	}
 
-	if err = os.Chmod(localpath, 0770); err != nil {
+	if err = os.Chmod(download, 0770); err != nil {
 		return errors.New("failed to chmod target binary: " + err.Error())
 	}
+	if err = os.Rename(download, localpath); err != nil {
+		return errors.New("failed to replace target binary: " + err.Error())
+	}
+	// The binary no longer comes from a build
+	_ = os.Remove(cfg.FilePath(config.LocalBuildFile))
 
 	log.Printf("[*] Updated target binary")
 	return nil
 }
+
+// pendingUpdate returns an identifier of the remote binary that syncBinary would replace the local one with, it is
+// empty when the local binary is up to date. Nothing is downloaded so it is cheap enough to poll while fuzzing.
+func (task *SyncTargetBinaryTask) pendingUpdate(cfg *config.Config) (string, error) {
+	if build, err := task.remoteBuild(cfg); err != nil {
+		return "", err
+	} else if build != "" {
+		if build == localBuild(cfg) {
+			return "", nil
+		}
+		return build, nil
+	}
+
+	localpath := cfg.FilePath(config.LocalFuzzerFile)
+	key, attrs, err := task.remoteBundle(cfg)
+	if err != nil {
+		return "", err
+	}
+	var info os.FileInfo
+	if attrs != nil {
+		info, err = os.Lstat(localpath)
+	} else {
+		key = cfg.FilePath(config.CloudFuzzerFile)
+		if attrs, err = task.cloud.FileInfo(key); err != nil {
+			return "", err
+		}
+		info, err = os.Stat(localpath)
+		if _, bundled := bundleDirectory(cfg); bundled {
+			// The bundle was removed, syncBinary always fetches the binary again
+			info = nil
+		}
+	}
+	if err == nil && info != nil && !attrs.ModTime.After(info.ModTime()) {
+		return "", nil
+	}
+	return fmt.Sprintf("%s@%s", key, attrs.ModTime.UTC().Format(time.RFC3339Nano)), nil
+}
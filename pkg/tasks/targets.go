@@ -0,0 +1,130 @@
+package tasks
+
+import (
+	"FuzzerMan/pkg/cloudutil"
+	"FuzzerMan/pkg/config"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// A campaign can hold several targets under `targets/<name>/`, each target is treated like a campaign of its own
+// with the configuration from config.ForTarget. Campaigns without any targets keep using the `fuzzer` binary at the
+// root of the campaign.
+
+// remoteTargets returns the names of the campaign's targets in the bucket, one for each `targets/<name>/` with a
+// `fuzzer`, `build` or bundle. Only the target directories are listed, not the corpus and logs within them.
+func remoteTargets(cloud *cloudutil.Client, cfg *config.Config) ([]string, error) {
+	var out []string
+	prefix := cfg.CloudPath(config.TargetDirectory) + "/"
+	names, err := cloud.ListDirectories(prefix)
+	if err != nil {
+		return out, err
+	}
+
+	for _, name := range names {
+		for _, fn := range append([]string{"fuzzer", "build"}, bundleNames...) {
+			exists, err := cloud.Exists(prefix + name + "/" + fn)
+			if err != nil {
+				return out, err
+			}
+			if exists {
+				out = append(out, name)
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+// localTargets returns the configuration of every target whose binary has been synced to this instance. It returns
+// nil when the campaign has no targets.
+func localTargets(cfg *config.Config) []*config.Config {
+	var out []*config.Config
+	dirs, err := os.ReadDir(filepath.Join(cfg.WorkDirectory, string(config.TargetDirectory)))
+	if err != nil {
+		return out
+	}
+
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		target := cfg.ForTarget(dir.Name())
+		if _, err := os.Stat(target.FilePath(config.LocalFuzzerFile)); err == nil {
+			out = append(out, target)
+		}
+	}
+	return out
+}
+
+// CheckTargetBinaries checks the binaries synced for the campaign are executable, the binary of every target when
+// the campaign has targets or the `fuzzer` at the root of the campaign otherwise
+func CheckTargetBinaries(cfg *config.Config) error {
+	targets := localTargets(cfg)
+	if len(targets) == 0 {
+		return checkTargetBinary(cfg)
+	}
+	for _, target := range targets {
+		if err := checkTargetBinary(target); err != nil {
+			return fmt.Errorf("target %s: %s", target.Target, err.Error())
+		}
+	}
+	return nil
+}
+
+func checkTargetBinary(cfg *config.Config) error {
+	if info, err := os.Stat(cfg.FilePath(config.LocalFuzzerFile)); err != nil {
+		return errors.New(fmt.Sprintf("unable to stat target binary: %s", err.Error()))
+	} else {
+		// Check for executable bit to be set on any of owner/group/everyone
+		if info.Mode()&0111 == 0 {
+			return errors.New("target binary is not executable")
+		}
+	}
+	return nil
+}
+
+// targetTasks keeps a separate instance of a task for each target, so state like the merge lock or the time of the
+// last reproduction run is tracked per target.
+type targetTasks[T RunnableTask] struct {
+	tasks map[string]T
+}
+
+// get returns the task for the target, initializing a new one with newTask if there is none yet
+func (t *targetTasks[T]) get(ctx context.Context, cfg *config.Config, newTask func() T) (T, error) {
+	if task, found := t.tasks[cfg.Target]; found {
+		return task, nil
+	}
+
+	task := newTask()
+	if err := task.Initialize(ctx, cfg); err != nil {
+		return task, err
+	}
+	if t.tasks == nil {
+		t.tasks = make(map[string]T)
+	}
+	t.tasks[cfg.Target] = task
+	return task, nil
+}
+
+// runAll runs the task once for every target, an error for one target doesn't stop the others from running. The
+// first error is returned.
+func (t *targetTasks[T]) runAll(ctx context.Context, targets []*config.Config, newTask func() T) error {
+	var firstErr error
+	for _, cfg := range targets {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		task, err := t.get(ctx, cfg, newTask)
+		if err == nil {
+			err = task.Run()
+		}
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("target %s: %s", cfg.Target, err.Error())
+		}
+	}
+	return firstErr
+}
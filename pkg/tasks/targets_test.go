@@ -0,0 +1,122 @@
+package tasks
+
+import (
+	"FuzzerMan/pkg/cloudutil"
+	"FuzzerMan/pkg/config"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalTargets(t *testing.T) {
+	cfg := &config.Config{
+		WorkDirectory: t.TempDir(),
+		CloudStorage:  config.CloudStorageConfig{Prefix: "campaigns/example"},
+	}
+	if targets := localTargets(cfg); len(targets) != 0 {
+		t.Fatalf("expected no targets, got %d", len(targets))
+	}
+
+	// Only targets with a synced binary are fuzzed
+	for _, name := range []string{"parse", "decode"} {
+		target := cfg.ForTarget(name)
+		_ = os.MkdirAll(target.WorkDirectory, 0770)
+		_ = os.WriteFile(target.FilePath(config.LocalFuzzerFile), []byte{}, 0770)
+	}
+	_ = os.MkdirAll(filepath.Join(cfg.WorkDirectory, "targets", "removed"), 0770)
+
+	targets := localTargets(cfg)
+	if len(targets) != 2 || targets[0].Target != "decode" || targets[1].Target != "parse" {
+		t.Fatalf("unexpected targets: %+v", targets)
+	}
+	if prefix := targets[0].CloudPath(config.CorpusDirectory); prefix != "campaigns/example/targets/decode/corpus" {
+		t.Errorf("unexpected corpus prefix %s", prefix)
+	}
+	if localTargets(targets[0]) != nil {
+		t.Errorf("expected a target to have no targets of its own")
+	}
+}
+
+func TestNextTurn(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "targets.turn")
+	for expected := 0; expected < 3; expected++ {
+		// Every call reads the counter from disk, like a new task for each of MultiFuzzerMan's time slots
+		if turn := nextTurn(fn); turn != expected {
+			t.Errorf("expected turn %d, got %d", expected, turn)
+		}
+	}
+}
+
+func TestCheckTargetBinaries(t *testing.T) {
+	cfg := &config.Config{WorkDirectory: t.TempDir()}
+	if err := CheckTargetBinaries(cfg); err == nil {
+		t.Errorf("expected an error without any binary")
+	}
+
+	// A campaign with only targets doesn't need a root `fuzzer`
+	target := cfg.ForTarget("parse")
+	_ = os.MkdirAll(target.WorkDirectory, 0770)
+	_ = os.WriteFile(target.FilePath(config.LocalFuzzerFile), []byte{}, 0660)
+	if err := CheckTargetBinaries(cfg); err == nil {
+		t.Errorf("expected an error for a target binary that isn't executable")
+	}
+	_ = os.Chmod(target.FilePath(config.LocalFuzzerFile), 0770)
+	if err := CheckTargetBinaries(cfg); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestSyncTargetsPastFailure(t *testing.T) {
+	bucket := t.TempDir()
+	cfg := &config.Config{WorkDirectory: t.TempDir()}
+	cfg.CloudStorage.BucketURL = "file://" + bucket
+	cfg.CloudStorage.Prefix = "campaign"
+	upload := func(name, content string) {
+		fn := filepath.Join(bucket, "campaign", name)
+		_ = os.MkdirAll(filepath.Dir(fn), 0770)
+		_ = os.WriteFile(fn, []byte(content), 0660)
+	}
+	// The first target's build pointer is broken, the targets after it are still synced
+	upload("targets/a/build", "not a hash")
+	upload("targets/b/fuzzer", "b")
+	stale := cfg.ForTarget("removed")
+	_ = os.MkdirAll(stale.WorkDirectory, 0770)
+	_ = os.WriteFile(stale.FilePath(config.LocalFuzzerFile), []byte("removed"), 0770)
+
+	task := &SyncTargetBinaryTask{}
+	if err := task.Initialize(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := task.Run(); err == nil {
+		t.Errorf("expected the broken target to fail")
+	}
+	if content, _ := os.ReadFile(cfg.ForTarget("b").FilePath(config.LocalFuzzerFile)); string(content) != "b" {
+		t.Errorf("expected target b to be synced, got %q", content)
+	}
+	if _, err := os.Stat(stale.FilePath(config.LocalFuzzerFile)); err == nil {
+		t.Errorf("expected the removed target's binary to be removed")
+	}
+}
+
+func TestRemoteTargets(t *testing.T) {
+	bucket := t.TempDir()
+	cfg := &config.Config{WorkDirectory: t.TempDir()}
+	cfg.CloudStorage.BucketURL = "file://" + bucket
+	cfg.CloudStorage.Prefix = "campaign"
+	for _, fn := range []string{"targets/build/build", "targets/bundle/bundle.zip", "targets/fuzzer/fuzzer",
+		"targets/fuzzer/corpus/input", "targets/removed/corpus/input", "targets/stray"} {
+		fn = filepath.Join(bucket, "campaign", fn)
+		_ = os.MkdirAll(filepath.Dir(fn), 0770)
+		_ = os.WriteFile(fn, []byte{}, 0660)
+	}
+
+	targets, err := remoteTargets(cloudutil.NewClient(context.Background(), cfg.CloudStorage.BucketURL), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(targets, ",") != "build,bundle,fuzzer" {
+		t.Errorf("unexpected targets %v", targets)
+	}
+}
@@ -1,6 +1,8 @@
 package tasks
 
 import (
+	"FuzzerMan/pkg/config"
+	"FuzzerMan/pkg/engine"
 	"bytes"
 	"fmt"
 	"io"
@@ -8,16 +10,29 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 )
 
-// MultipartFileUpload will perform a multi-part POST request to the given url.
+// NamedReader allows content that is not backed by a file to be sent as a file field by MultipartFileUpload
+type NamedReader struct {
+	io.Reader
+	Filename string
+}
+
+func (r NamedReader) Name() string {
+	return r.Filename
+}
+
+// MultipartFileUpload will perform a multi-part POST request to the given url. Readers with a filename, either an
+// *os.File or a NamedReader, are sent as file fields.
 func MultipartFileUpload(client *http.Client, url string, values map[string]io.Reader) (err error) {
 	var b bytes.Buffer
 	w := multipart.NewWriter(&b)
 
 	for key, r := range values {
 		var fw io.Writer
-		if x, ok := r.(*os.File); ok {
+		if x, ok := r.(interface{ Name() string }); ok {
 			if fw, err = w.CreateFormFile(key, filepath.Base(x.Name())); err != nil {
 				return
 			}
@@ -50,3 +65,45 @@ func MultipartFileUpload(client *http.Client, url string, values map[string]io.R
 
 	return
 }
+
+// nextTurn returns the counter kept in fn and increments it. The counter is kept in the work directory as
+// MultiFuzzerMan creates new tasks for every time slot, a counter in the task would always start over.
+func nextTurn(fn string) int {
+	turn := 0
+	if content, err := os.ReadFile(fn); err == nil {
+		turn, _ = strconv.Atoi(strings.TrimSpace(string(content)))
+	}
+	if turn < 0 {
+		turn = 0
+	}
+	_ = os.WriteFile(fn, []byte(strconv.Itoa(turn+1)), 0660)
+	return turn
+}
+
+// targetEnvironment builds the environment the target binary is run with
+func targetEnvironment(cfg *config.Config) []string {
+	var env []string
+	if cfg.Fuzzer.IncludeHostEnv {
+		env = os.Environ()
+	}
+	env = append(env, cfg.Fuzzer.Environment...)
+	if dir, ok := bundleDirectory(cfg); ok {
+		env = bundleEnvironment(env, dir)
+	}
+	return env
+}
+
+// engineOptions builds the options the fuzzing engine's commands are created from
+func engineOptions(cfg *config.Config) engine.Options {
+	return engine.Options{
+		Binary:       cfg.FilePath(config.LocalFuzzerFile),
+		CorpusDir:    cfg.WorkPath(config.CorpusDirectory),
+		ArtifactDir:  cfg.WorkPath(config.ArtifactDirectory),
+		WorkDir:      cfg.WorkPath(config.EngineDirectory),
+		Jobs:         cfg.Fuzzer.ForkCount,
+		MaxTotalTime: cfg.Fuzzer.MaxTotalTime,
+		FuzzFunction: cfg.Fuzzer.FuzzFunction,
+		Arguments:    cfg.Fuzzer.Arguments,
+		Environment:  targetEnvironment(cfg),
+	}
+}
@@ -0,0 +1,88 @@
+package tasks
+
+import (
+	"FuzzerMan/pkg/config"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SignedManifest is the message signed in a `.sig` object. It ties the binary's hash to the object it was published
+// as, so a signature can't be replayed for another campaign, target or build.
+type SignedManifest struct {
+	Key    string `json:"key"`
+	SHA256 string `json:"sha256"`
+}
+
+// verifyBinary checks the binary downloaded from binaryKey against the `.sig` object next to it when the configuration
+// has TrustedKeys. The signature object holds the manifest on its first line and the base64 encoded signature of
+// that line on the second.
+func (task *SyncTargetBinaryTask) verifyBinary(cfg *config.Config, binaryPath, binaryKey string) error {
+	if len(cfg.TrustedKeys) == 0 {
+		return nil
+	}
+	keys, err := parseTrustedKeys(cfg.TrustedKeys)
+	if err != nil {
+		return err
+	}
+
+	hash, err := fileSHA256(binaryPath)
+	if err != nil {
+		return err
+	}
+	signature, err := task.cloud.ReadFile(binaryKey+".sig", nil)
+	if err != nil {
+		return fmt.Errorf("failed to read signature: %s", err.Error())
+	}
+	manifest, err := verifySignature(keys, signature)
+	if err != nil {
+		return err
+	}
+	if manifest.Key != binaryKey {
+		return fmt.Errorf("signature is for '%s', not '%s'", manifest.Key, binaryKey)
+	}
+	if manifest.SHA256 != hash {
+		return errors.New("signature does not match the binary's hash")
+	}
+	return nil
+}
+
+func parseTrustedKeys(encoded []string) ([]ed25519.PublicKey, error) {
+	var out []ed25519.PublicKey
+	for _, key := range encoded {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+		if err != nil || len(decoded) != ed25519.PublicKeySize {
+			return out, fmt.Errorf("invalid trusted key '%s', expected a base64 encoded ed25519 public key", key)
+		}
+		out = append(out, decoded)
+	}
+	return out, nil
+}
+
+// verifySignature checks the signed manifest in a signature object against each of the keys and returns it
+func verifySignature(keys []ed25519.PublicKey, content []byte) (*SignedManifest, error) {
+	message, encodedSignature, found := bytes.Cut(bytes.TrimSpace(content), []byte("\n"))
+	if !found {
+		return nil, errors.New("malformed signature, expected a manifest and a signature")
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encodedSignature)))
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return nil, errors.New("malformed signature")
+	}
+	message = bytes.TrimSuffix(message, []byte("\r"))
+	for _, key := range keys {
+		if !ed25519.Verify(key, message, signature) {
+			continue
+		}
+		var manifest SignedManifest
+		if err = json.Unmarshal(message, &manifest); err != nil {
+			return nil, errors.New("malformed signed manifest: " + err.Error())
+		}
+		return &manifest, nil
+	}
+	return nil, errors.New("signature does not match any trusted key")
+}
@@ -0,0 +1,58 @@
+package tasks
+
+import (
+	"FuzzerMan/pkg/config"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSyncVerifiesSignature(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(nil)
+	_, untrusted, _ := ed25519.GenerateKey(nil)
+
+	bucket := t.TempDir()
+	cfg := &config.Config{WorkDirectory: t.TempDir()}
+	cfg.CloudStorage.BucketURL = "file://" + bucket
+	cfg.CloudStorage.Prefix = "campaign"
+	cfg.TrustedKeys = []string{base64.StdEncoding.EncodeToString(public)}
+
+	upload := func(content string, key ed25519.PrivateKey, signedKey string, mtime time.Time) {
+		sum := sha256.Sum256([]byte(content))
+		manifest, _ := json.Marshal(SignedManifest{Key: signedKey, SHA256: hex.EncodeToString(sum[:])})
+		signature := base64.StdEncoding.EncodeToString(ed25519.Sign(key, manifest))
+		_ = os.MkdirAll(filepath.Join(bucket, "campaign"), 0770)
+		_ = os.WriteFile(filepath.Join(bucket, "campaign", "fuzzer"), []byte(content), 0660)
+		_ = os.WriteFile(filepath.Join(bucket, "campaign", "fuzzer.sig"), []byte(string(manifest)+"\n"+signature+"\n"), 0660)
+		_ = os.Chtimes(filepath.Join(bucket, "campaign", "fuzzer"), mtime, mtime)
+	}
+
+	task := &SyncTargetBinaryTask{}
+	upload("v1", private, "campaign/fuzzer", time.Now().Add(-time.Hour))
+	if err := task.Initialize(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := task.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	upload("v2", untrusted, "campaign/fuzzer", time.Now().Add(time.Hour))
+	if err := task.Run(); err == nil {
+		t.Errorf("expected a binary signed by an untrusted key to be refused")
+	}
+	// A valid signature from another campaign can't be replayed
+	upload("v3", private, "other/fuzzer", time.Now().Add(2*time.Hour))
+	if err := task.Run(); err == nil {
+		t.Errorf("expected a signature for another object to be refused")
+	}
+	if local, _ := os.ReadFile(cfg.FilePath(config.LocalFuzzerFile)); string(local) != "v1" {
+		t.Errorf("expected the previous binary to be kept, got %q", local)
+	}
+}